@@ -0,0 +1,90 @@
+package bytemap
+
+// Arrays are ByteMaps whose keys are implicit positional indexes. Instead of
+// key entries, an array has one fixed width element entry per value, consisting
+// of the value's type and its offset, followed by the values themselves.
+//
+// Because element entries have a fixed width, GetIndex can locate an element
+// without scanning. TypeNil elements point at the offset at which the next
+// value would have been stored, so the first element entry always points at
+// the end of the element region, which gives us the number of elements.
+//
+// Arrays don't have a key region, so key based accessors like Get and Iterate
+// treat them as empty. Use GetIndex and AsSlice instead.
+
+const (
+	sizeElement = SizeValueType + SizeValueOffset
+)
+
+// NewArray creates a new ByteMap that stores the given values positionally.
+func NewArray(values []interface{}) ByteMap {
+	header := newHeader(FlagArray)
+	elementsLen := len(values) * sizeElement
+	valuesLen := 0
+	for _, value := range values {
+		valuesLen += encodedLength(value)
+	}
+
+	bm := make(ByteMap, len(header)+elementsLen+valuesLen)
+	copy(bm, header)
+	elementOffset := len(header)
+	valueOffset := elementOffset + elementsLen
+	for _, value := range values {
+		t, n := encodeValue(bm[valueOffset:], value)
+		bm[elementOffset] = t
+		enc.PutUint32(bm[elementOffset+SizeValueType:], uint32(valueOffset))
+		elementOffset += sizeElement
+		valueOffset += n
+	}
+	return bm
+}
+
+// IsArray indicates whether this ByteMap was created with NewArray.
+func (bm ByteMap) IsArray() bool {
+	return bm.flags()&FlagArray == FlagArray
+}
+
+// ArrayLen returns the number of elements in this array, or 0 if this ByteMap
+// isn't an array.
+func (bm ByteMap) ArrayLen() int {
+	if !bm.IsArray() {
+		return 0
+	}
+	start := bm.headerLen()
+	end, ok := bm.uint32At(start + SizeValueType)
+	if !ok || end < start || end > len(bm) {
+		return 0
+	}
+	return (end - start) / sizeElement
+}
+
+// GetIndex gets the value at the given index of this array, or nil if the
+// index is out of range or this ByteMap isn't an array.
+func (bm ByteMap) GetIndex(i int) interface{} {
+	if i < 0 || i >= bm.ArrayLen() {
+		return nil
+	}
+	elementOffset := bm.headerLen() + i*sizeElement
+	t := bm[elementOffset]
+	if t == TypeNil {
+		return nil
+	}
+	valueOffset, ok := bm.uint32At(elementOffset + SizeValueType)
+	if !ok {
+		return nil
+	}
+	return bm.decodeValueAt(valueOffset, t)
+}
+
+// AsSlice returns a slice representation of this array, or nil if this
+// ByteMap isn't an array.
+func (bm ByteMap) AsSlice() []interface{} {
+	if !bm.IsArray() {
+		return nil
+	}
+	result := make([]interface{}, bm.ArrayLen())
+	for i := range result {
+		result[i] = bm.GetIndex(i)
+	}
+	return result
+}
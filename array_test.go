@@ -0,0 +1,53 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArray(t *testing.T) {
+	values := []interface{}{"a", nil, 5, []float64{1, 2}, true, nil}
+	bm := NewArray(values)
+	assert.True(t, bm.IsArray())
+	assert.False(t, New(m).IsArray())
+	assert.Equal(t, len(values), bm.ArrayLen())
+	for i, value := range values {
+		assert.Equal(t, value, bm.GetIndex(i))
+	}
+	assert.Nil(t, bm.GetIndex(-1))
+	assert.Nil(t, bm.GetIndex(len(values)))
+	assert.Equal(t, values, bm.AsSlice())
+
+	// Arrays have no keys
+	assert.Nil(t, bm.Get("0"))
+	assert.Empty(t, bm.AsMap())
+
+	// Maps aren't arrays
+	assert.Nil(t, New(m).GetIndex(0))
+	assert.Nil(t, New(m).AsSlice())
+}
+
+func TestArrayEmpty(t *testing.T) {
+	bm := NewArray(nil)
+	assert.True(t, bm.IsArray())
+	assert.Zero(t, bm.ArrayLen())
+	assert.Nil(t, bm.GetIndex(0))
+	assert.Empty(t, bm.AsSlice())
+}
+
+func TestArrayAllNil(t *testing.T) {
+	bm := NewArray([]interface{}{nil, nil, nil})
+	assert.Equal(t, 3, bm.ArrayLen())
+	assert.Equal(t, []interface{}{nil, nil, nil}, bm.AsSlice())
+}
+
+func TestArrayTruncated(t *testing.T) {
+	bm := NewArray([]interface{}{"a", 5, "c"})
+	for i := 0; i < len(bm); i++ {
+		truncated := bm[:i]
+		for j := 0; j < 3; j++ {
+			truncated.GetIndex(j)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package bytemap
+
+import (
+	"math/big"
+)
+
+// *big.Int and *big.Float values are stored using their GobEncode
+// representation (which preserves sign, precision and rounding mode) with a 4
+// byte length prefix, since the representation of a large enough value doesn't
+// fit a 2 byte length. Nil pointers are stored as nil.
+
+type gobEncoder interface {
+	GobEncode() ([]byte, error)
+}
+
+func encodeGob(slice []byte, t byte, v gobEncoder) (byte, int) {
+	b := gobBytes(v)
+	if b == nil {
+		return TypeNil, 0
+	}
+	enc.PutUint32(slice, uint32(len(b)))
+	copy(slice[4:], b)
+	return t, len(b) + 4
+}
+
+func gobLength(v gobEncoder) int {
+	b := gobBytes(v)
+	if b == nil {
+		return 0
+	}
+	return len(b) + 4
+}
+
+func gobBytes(v gobEncoder) []byte {
+	// GobEncode returns nil for nil pointers
+	b, err := v.GobEncode()
+	if err != nil {
+		return nil
+	}
+	return b
+}
+
+func decodeGob(t byte, b []byte) interface{} {
+	switch t {
+	case TypeBigInt:
+		result := new(big.Int)
+		if result.GobDecode(b) != nil {
+			return nil
+		}
+		return result
+	case TypeBigFloat:
+		result := new(big.Float)
+		if result.GobDecode(b) != nil {
+			return nil
+		}
+		return result
+	}
+	return nil
+}
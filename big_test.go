@@ -0,0 +1,57 @@
+package bytemap
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBigInt(t *testing.T) {
+	positive, _ := new(big.Int).SetString("1234567890123456789012345678901234567890", 10)
+	negative := new(big.Int).Neg(positive)
+	zero := new(big.Int)
+	bm := New(map[string]interface{}{
+		"positive": positive,
+		"negative": negative,
+		"zero":     zero,
+		"nil":      (*big.Int)(nil),
+	})
+	for key, expected := range map[string]*big.Int{"positive": positive, "negative": negative, "zero": zero} {
+		actual, ok := bm.Get(key).(*big.Int)
+		if assert.True(t, ok, key) {
+			assert.Zero(t, expected.Cmp(actual), key)
+		}
+	}
+	assert.Nil(t, bm.Get("nil"))
+	assert.Equal(t, len(bm), EstimateSize(map[string]interface{}{"positive": positive, "negative": negative, "zero": zero, "nil": (*big.Int)(nil)}))
+
+	// Its gob form is longer than a 2 byte length can hold
+	huge := new(big.Int).Lsh(big.NewInt(1), 8*math.MaxUint16)
+	bm = New(map[string]interface{}{"huge": huge, "after": 1})
+	assert.NoError(t, bm.Validate())
+	if actual, ok := bm.Get("huge").(*big.Int); assert.True(t, ok) {
+		assert.Zero(t, huge.Cmp(actual))
+	}
+	assert.Equal(t, 1, bm.Get("after"))
+}
+
+func TestBigFloat(t *testing.T) {
+	precise, _ := new(big.Float).SetPrec(200).SetString("3.14159265358979323846264338327950288419716939937510582097494459")
+	negative := new(big.Float).Neg(precise)
+	zero := new(big.Float)
+	bm := New(map[string]interface{}{
+		"precise":  precise,
+		"negative": negative,
+		"zero":     zero,
+	})
+	for key, expected := range map[string]*big.Float{"precise": precise, "negative": negative, "zero": zero} {
+		actual, ok := bm.Get(key).(*big.Float)
+		if assert.True(t, ok, key) {
+			assert.Zero(t, expected.Cmp(actual), key)
+			assert.Equal(t, expected.Prec(), actual.Prec(), key)
+			assert.Equal(t, expected.Text('g', 60), actual.Text('g', 60), key)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package bytemap
+
+// Builder incrementally builds a ByteMap from key/value pairs that are added
+// one at a time in any order. Values are encoded as they're added, so the
+// Builder doesn't hold on to the original values. The zero value is ready to
+// use.
+type Builder struct {
+	entries []builderEntry
+	index   map[string]int
+	values  []byte
+	sorted  bool
+}
+
+type builderEntry struct {
+	key         string
+	t           byte
+	valueOffset int
+	valueLen    int
+}
+
+// NewBuilder creates a new, empty Builder.
+func NewBuilder() *Builder {
+	return &Builder{}
+}
+
+// Grow grows the Builder's internal buffers so that at least nKeys more keys
+// with a total of estBytes more encoded value bytes can be added without
+// reallocating, similar to strings.Builder.Grow.
+func (b *Builder) Grow(nKeys int, estBytes int) {
+	if nKeys > 0 {
+		if cap(b.entries)-len(b.entries) < nKeys {
+			entries := make([]builderEntry, len(b.entries), len(b.entries)+nKeys)
+			copy(entries, b.entries)
+			b.entries = entries
+		}
+		if b.index == nil {
+			b.index = make(map[string]int, nKeys)
+		}
+	}
+	if estBytes > 0 && cap(b.values)-len(b.values) < estBytes {
+		values := make([]byte, len(b.values), len(b.values)+estBytes)
+		copy(values, b.values)
+		b.values = values
+	}
+}
+
+// Put adds the given key/value pair. If the key was already added, its value
+// is replaced.
+func (b *Builder) Put(key string, value interface{}) {
+	valueOffset := len(b.values)
+	n := encodedLength(value)
+	if cap(b.values)-valueOffset < n {
+		values := make([]byte, valueOffset, 2*cap(b.values)+n)
+		copy(values, b.values)
+		b.values = values
+	}
+	t, n := encodeValue(b.values[valueOffset:valueOffset+n], value)
+	b.values = b.values[:valueOffset+n]
+	b.add(builderEntry{key, t, valueOffset, n})
+}
+
+// PutMerge adds the given key/value pair like Put, but if the key was already
+// added, it stores merge(old, value) instead, where old is the current value
+// decoded the way Get would decode it. This allows aggregating repeated keys
+// (e.g. summing counts) without collecting them in a separate map first. Like
+// with Put, the bytes of replaced values remain in the Builder's buffer until
+// it's discarded.
+func (b *Builder) PutMerge(key string, value interface{}, merge func(old, new interface{}) interface{}) {
+	if i, found := b.index[key]; found {
+		e := b.entries[i]
+		var old interface{}
+		if e.t != TypeNil {
+			old = ByteMap(b.values).decodeValueIn(defaultFormat, e.valueOffset, e.t)
+		}
+		value = merge(old, value)
+	}
+	b.Put(key, value)
+}
+
+// putRaw adds the given key with a value of type t that's already encoded.
+func (b *Builder) putRaw(key string, t byte, valueBytes []byte) {
+	valueOffset := len(b.values)
+	b.values = append(b.values, valueBytes...)
+	b.add(builderEntry{key, t, valueOffset, len(valueBytes)})
+}
+
+func (b *Builder) add(e builderEntry) {
+	key := e.key
+	if b.index == nil {
+		b.index = make(map[string]int)
+	}
+	if i, found := b.index[key]; found {
+		b.entries[i] = e
+		return
+	}
+	if len(b.entries) == 0 {
+		b.sorted = true
+	} else if b.sorted && key < b.entries[len(b.entries)-1].key {
+		b.sorted = false
+	}
+	b.index[key] = len(b.entries)
+	b.entries = append(b.entries, e)
+}
+
+// Overwrite replaces the value of an already added key by encoding the new
+// value in place, which avoids growing the Builder's buffer. This only works if
+// the new value encodes to the same number of bytes as the current one (e.g.
+// when refining a numeric value), so Overwrite returns false without changing
+// anything if the key hasn't been added yet or the widths differ.
+func (b *Builder) Overwrite(key string, value interface{}) bool {
+	i, found := b.index[key]
+	if !found {
+		return false
+	}
+	e := &b.entries[i]
+	if encodedLength(value) != e.valueLen {
+		return false
+	}
+	e.t, _ = encodeValue(b.values[e.valueOffset:e.valueOffset+e.valueLen], value)
+	return true
+}
+
+// Len returns the number of distinct keys added so far.
+func (b *Builder) Len() int {
+	return len(b.entries)
+}
+
+// Build builds a ByteMap containing all of the key/value pairs added so far.
+// The Builder can continue to be used afterwards. Build returns an error if any
+// key is longer than MaxKeyLen bytes.
+func (b *Builder) Build() (ByteMap, error) {
+	entries := make([]rawEntry, 0, len(b.entries))
+	for _, e := range b.entries {
+		entries = append(entries, rawEntry{key: e.key, t: e.t, value: b.values[e.valueOffset : e.valueOffset+e.valueLen]})
+	}
+	if !b.sorted {
+		sortRawEntries(entries)
+	}
+	return buildFromRaw(nil, entries)
+}
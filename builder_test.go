@@ -0,0 +1,153 @@
+package bytemap
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder()
+	for key, value := range m {
+		b.Put(key, value)
+	}
+	assert.Equal(t, len(m), b.Len())
+	bm, err := b.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, New(m), bm)
+
+	b.Put("string", "replaced")
+	assert.Equal(t, len(m), b.Len())
+	bm, err = b.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, "replaced", bm.Get("string"))
+}
+
+func TestBuilderSorted(t *testing.T) {
+	var b Builder
+	b.Put("a", 1)
+	b.Put("b", nil)
+	b.Put("c", "3")
+	bm, err := b.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, New(map[string]interface{}{"a": 1, "b": nil, "c": "3"}), bm)
+}
+
+func TestBuilderOverwrite(t *testing.T) {
+	b := NewBuilder()
+	b.Put("count", 1)
+	b.Put("name", "abc")
+	b.Put("nil", nil)
+	valuesLen := len(b.values)
+
+	assert.True(t, b.Overwrite("count", 2))
+	assert.True(t, b.Overwrite("name", "xyz"))
+	assert.True(t, b.Overwrite("count", 3))
+	assert.True(t, b.Overwrite("count", 3.5), "Same width, different type")
+	assert.Equal(t, valuesLen, len(b.values), "Overwrite should not grow the buffer")
+
+	assert.False(t, b.Overwrite("unknown", 1), "Absent key")
+	assert.False(t, b.Overwrite("name", "longer"), "Different width")
+	assert.False(t, b.Overwrite("count", int32(3)), "Different width")
+	assert.False(t, b.Overwrite("nil", 1), "Different width")
+	assert.False(t, b.Overwrite("count", nil), "Different width")
+
+	bm, err := b.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, 3.5, bm.Get("count"))
+	assert.Equal(t, "xyz", bm.Get("name"))
+	assert.Nil(t, bm.Get("nil"))
+}
+
+func TestBuilderPutMerge(t *testing.T) {
+	sum := func(old, new interface{}) interface{} {
+		return old.(int) + new.(int)
+	}
+	keepLast := func(old, new interface{}) interface{} {
+		return new
+	}
+	concat := func(old, new interface{}) interface{} {
+		if old == nil {
+			return new
+		}
+		return old.(string) + new.(string)
+	}
+
+	b := NewBuilder()
+	for i := 1; i <= 3; i++ {
+		b.PutMerge("sum", i, sum)
+		b.PutMerge("last", fmt.Sprint(i), keepLast)
+	}
+	b.Put("nil", nil)
+	b.PutMerge("nil", "a", concat)
+	b.PutMerge("nil", "b", concat)
+	assert.Equal(t, 3, b.Len())
+	bm, err := b.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, New(map[string]interface{}{"sum": 6, "last": "3", "nil": "ab"}), bm)
+}
+
+func TestBuilderEmpty(t *testing.T) {
+	bm, err := NewBuilder().Build()
+	assert.NoError(t, err)
+	assert.Empty(t, bm)
+}
+
+func TestBuilderKeyLen(t *testing.T) {
+	b := NewBuilder()
+	b.Put(strings.Repeat("k", MaxKeyLen+1), 1)
+	_, err := b.Build()
+	assert.Error(t, err)
+
+	b = NewBuilder()
+	longKey := strings.Repeat("k", MaxKeyLen)
+	b.Put(longKey, 1)
+	bm, err := b.Build()
+	if assert.NoError(t, err) {
+		assert.Equal(t, New(map[string]interface{}{longKey: 1}), bm)
+		assert.Equal(t, 1, bm.Get(longKey))
+	}
+}
+
+func TestBuilderGrow(t *testing.T) {
+	b := NewBuilder()
+	b.Put("a", 1)
+	b.Grow(10, 100)
+	assert.True(t, cap(b.entries) >= 11)
+	assert.True(t, cap(b.values) >= 108)
+	b.Put("b", 2)
+	bm, err := b.Build()
+	assert.NoError(t, err)
+	assert.Equal(t, New(map[string]interface{}{"a": 1, "b": 2}), bm)
+}
+
+var wideKeys = make([]string, 1000)
+
+func init() {
+	for i := range wideKeys {
+		wideKeys[i] = fmt.Sprintf("key%04d", (i*7919)%len(wideKeys))
+	}
+}
+
+func BenchmarkBuilder(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		bu := NewBuilder()
+		for j, key := range wideKeys {
+			bu.Put(key, j)
+		}
+		bu.Build()
+	}
+}
+
+func BenchmarkBuilderGrow(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		bu := NewBuilder()
+		bu.Grow(len(wideKeys), len(wideKeys)*8)
+		for j, key := range wideKeys {
+			bu.Put(key, j)
+		}
+		bu.Build()
+	}
+}
@@ -1,10 +1,18 @@
 // Package bytemap provides a map[string]interface{} encoded as a byte slice.
+//
+// ByteMaps are immutable and none of their read methods modify the underlying
+// bytes or any other shared state, so a single ByteMap may be read from many
+// goroutines concurrently without synchronization.
 package bytemap
 
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
+	"io"
 	"math"
+	"math/big"
+	"math/bits"
 	"sort"
 	"time"
 )
@@ -29,12 +37,24 @@ const (
 	TypeBytes
 	TypeFloat64s
 	TypeInts
+	TypeInt8s
+	TypeBigInt
+	TypeBigFloat
+	TypeStrings
+	TypeByteMap
+	TypeCompressed
+	TypeProto
+	TypeJSON
 )
 
 const (
 	SizeKeyLen      = 2
 	SizeValueType   = 1
 	SizeValueOffset = 4
+
+	// MaxKeyLen is the length of the longest key that can be stored, which is
+	// limited by the SizeKeyLen bytes used to store key lengths.
+	MaxKeyLen = math.MaxUint16
 )
 
 var (
@@ -44,9 +64,16 @@ var (
 // ByteMap is an immutable map[string]interface{} backed by a byte array.
 type ByteMap []byte
 
-// New creates a new ByteMap from the given map
+// New creates a new ByteMap from the given map. Like Build, it panics if a key
+// is longer than MaxKeyLen bytes.
 func New(m map[string]interface{}) ByteMap {
-	return Build(func(cb func(string, interface{})) {
+	return mustBuild(newStrict(m))
+}
+
+// newStrict is like New, but returns an error instead of panicking. Parsers of
+// untrusted input use it.
+func newStrict(m map[string]interface{}) (ByteMap, error) {
+	return BuildStrict(func(cb func(string, interface{})) {
 		for key, value := range m {
 			cb(key, value)
 		}
@@ -66,6 +93,50 @@ func NewFloat(m map[string]float64) ByteMap {
 	}, false)
 }
 
+// NewBytes creates a new ByteMap from the given map without boxing each value
+// into an interface{}. Nil slices are stored as nil values and empty slices as
+// empty byte slices, so the two can be told apart when reading the map. Like
+// Build, it panics if a key is longer than MaxKeyLen bytes.
+func NewBytes(m map[string][]byte) ByteMap {
+	keys := make([]string, 0, len(m))
+	valuesLen := 0
+	for key, value := range m {
+		keys = append(keys, key)
+		if value != nil {
+			valuesLen += len(value) + 2
+		}
+	}
+	sort.Strings(keys)
+	header := mustHeaderFor(keys)
+	keysLen := len(header)
+	for _, key := range keys {
+		keysLen += KeyOverhead(key, m[key] != nil)
+	}
+
+	bm := make(ByteMap, keysLen+valuesLen)
+	keyOffset := copy(bm, header)
+	valueOffset := keysLen
+	for _, key := range keys {
+		value := m[key]
+		enc.PutUint16(bm[keyOffset:], uint16(len(key)))
+		keyOffset += SizeKeyLen
+		keyOffset += copy(bm[keyOffset:], key)
+		if value == nil {
+			bm[keyOffset] = TypeNil
+			keyOffset += SizeValueType
+			continue
+		}
+		bm[keyOffset] = TypeBytes
+		keyOffset += SizeValueType
+		enc.PutUint32(bm[keyOffset:], uint32(valueOffset))
+		keyOffset += SizeValueOffset
+		enc.PutUint16(bm[valueOffset:], uint16(len(value)))
+		valueOffset += 2
+		valueOffset += copy(bm[valueOffset:], value)
+	}
+	return bm
+}
+
 // FromSortedKeysAndValues constructs a ByteMap from sorted keys and values.
 func FromSortedKeysAndValues(keys []string, values []interface{}) ByteMap {
 	return Build(func(cb func(string, interface{})) {
@@ -84,28 +155,165 @@ func FromSortedKeysAndFloats(keys []string, values []float64) ByteMap {
 	}, nil, true)
 }
 
+// FromSortedKeysAndInts constructs a ByteMap from sorted keys and int64 values
+// without boxing each value into an interface{}. Values are stored as
+// TypeInt64. Like Build, it panics if a key is longer than MaxKeyLen bytes.
+func FromSortedKeysAndInts(keys []string, values []int64) ByteMap {
+	header := mustHeaderFor(keys)
+	keysLen := len(header)
+	for _, key := range keys {
+		keysLen += KeyOverhead(key, true)
+	}
+
+	bm := make(ByteMap, keysLen+len(values)*8)
+	keyOffset := copy(bm, header)
+	valueOffset := keysLen
+	for i, key := range keys {
+		enc.PutUint16(bm[keyOffset:], uint16(len(key)))
+		keyOffset += SizeKeyLen
+		keyOffset += copy(bm[keyOffset:], key)
+		bm[keyOffset] = TypeInt64
+		keyOffset += SizeValueType
+		enc.PutUint32(bm[keyOffset:], uint32(valueOffset))
+		keyOffset += SizeValueOffset
+		enc.PutUint64(bm[valueOffset:], uint64(values[i]))
+		valueOffset += 8
+	}
+	return bm
+}
+
+// mustHeaderFor checks the given keys the way Build does, panicking if any of
+// them is longer than MaxKeyLen bytes, and returns the header that a map
+// starting with them needs (see keyLooksLikeHeader).
+func mustHeaderFor(keys []string) []byte {
+	for _, key := range keys {
+		if err := checkKeyLen(key, MaxKeyLen); err != nil {
+			panic(err)
+		}
+	}
+	if len(keys) > 0 && keyLooksLikeHeader(keys[0]) {
+		return newHeader(0)
+	}
+	return nil
+}
+
+// KV is a single key/value pair.
+type KV struct {
+	Key   string
+	Value interface{}
+}
+
+// FromKVs constructs a ByteMap from the given pairs, which may be in any
+// order. The pairs slice itself is not modified. If the same key appears more
+// than once, the last pair wins, like it would when building a map.
+func FromKVs(kvs []KV) ByteMap {
+	sorted := make([]KV, len(kvs))
+	copy(sorted, kvs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+	deduped := sorted[:0]
+	for _, kv := range sorted {
+		if len(deduped) > 0 && deduped[len(deduped)-1].Key == kv.Key {
+			deduped[len(deduped)-1] = kv
+			continue
+		}
+		deduped = append(deduped, kv)
+	}
+	return FromSortedKVs(deduped)
+}
+
+// FromSortedKVs constructs a ByteMap from pairs that are already sorted by key
+// with no duplicate keys. The order is trusted and not checked.
+func FromSortedKVs(kvs []KV) ByteMap {
+	return Build(func(cb func(string, interface{})) {
+		for _, kv := range kvs {
+			cb(kv.Key, kv.Value)
+		}
+	}, nil, true)
+}
+
+// BuildOrdered builds a new ByteMap that stores the given pairs in the given
+// order rather than sorting them by key. Iterating over the resulting map
+// yields the pairs in their original order. Since the keys aren't sorted,
+// lookups always scan the whole key region.
+func BuildOrdered(pairs []KV) ByteMap {
+	return build(newHeader(FlagOrdered), func(cb func(string, interface{})) {
+		for _, pair := range pairs {
+			cb(pair.Key, pair.Value)
+		}
+	}, nil, true)
+}
+
 // Build builds a new ByteMap using a function that iterates over all included
 // key/value paris and another function that returns the value for a given key/
 // index. If iteratesSorted is true, then the iterate order of iterate is
 // considered to be in lexicographically sorted order over the keys and is
 // stable over multiple invocations, and valueFor is not needed.
+//
+// Keys are length-prefixed with a uint16, so Build panics if a key is longer
+// than MaxKeyLen bytes. Use BuildStrict to get an error instead.
 func Build(iterate func(func(string, interface{})), valueFor func(string) interface{}, iteratesSorted bool) ByteMap {
-	keysLen := 0
+	return build(nil, iterate, valueFor, iteratesSorted)
+}
+
+// BuildStrict is like Build, but returns an error instead of panicking if a key
+// is longer than MaxKeyLen bytes. Keys are checked before anything is encoded.
+func BuildStrict(iterate func(func(string, interface{})), valueFor func(string) interface{}, iteratesSorted bool) (ByteMap, error) {
+	return buildInto(nil, nil, iterate, valueFor, iteratesSorted)
+}
+
+// BuildFiltered is like Build with iteratesSorted false, but only includes the
+// keys for which include returns true. Excluded keys are dropped as they're
+// iterated, so valueFor is never called for them, which saves computing values
+// that would be discarded. Values that iterate passes for excluded keys are
+// ignored.
+func BuildFiltered(iterate func(func(string, interface{})), valueFor func(string) interface{}, include func(key string) bool) ByteMap {
+	return Build(func(cb func(string, interface{})) {
+		iterate(func(key string, value interface{}) {
+			if include(key) {
+				cb(key, value)
+			}
+		})
+	}, valueFor, false)
+}
+
+func build(header []byte, iterate func(func(string, interface{})), valueFor func(string) interface{}, iteratesSorted bool) ByteMap {
+	return mustBuild(buildInto(nil, header, iterate, valueFor, iteratesSorted))
+}
+
+func mustBuild(bm ByteMap, err error) ByteMap {
+	if err != nil {
+		panic(err)
+	}
+	return bm
+}
+
+// buildInto is like build, but builds into buf if it has enough capacity. buf
+// isn't touched if buildInto returns an error.
+func buildInto(buf []byte, header []byte, iterate func(func(string, interface{})), valueFor func(string) interface{}, iteratesSorted bool) (ByteMap, error) {
+	keysLen := len(header)
 	valuesLen := 0
+	var err error
 
 	recordKey := func(key string, value interface{}) {
-		valLen := encodedLength(value)
-		keysLen += len(key) + SizeKeyLen + SizeValueType
-		if valLen > 0 {
-			keysLen += SizeValueOffset
+		if err == nil {
+			err = checkKeyLen(key, MaxKeyLen)
 		}
+		keyLen, valLen := encodedEntryLength(key, value)
+		keysLen += keyLen
 		valuesLen += valLen
 	}
 
 	var finalIterate func(func(string, interface{}))
+	var firstKey string
 
 	if iteratesSorted {
+		first := true
 		iterate(func(key string, value interface{}) {
+			if first {
+				firstKey, first = key, false
+			}
 			recordKey(key, value)
 		})
 		finalIterate = iterate
@@ -116,6 +324,9 @@ func Build(iterate func(func(string, interface{})), valueFor func(string) interf
 			recordKey(key, value)
 		})
 		sort.Strings(sortedKeys)
+		if len(sortedKeys) > 0 {
+			firstKey = sortedKeys[0]
+		}
 
 		finalIterate = func(cb func(string, interface{})) {
 			for _, key := range sortedKeys {
@@ -123,10 +334,24 @@ func Build(iterate func(func(string, interface{})), valueFor func(string) interf
 			}
 		}
 	}
+	if err != nil {
+		return nil, err
+	}
+	if len(header) == 0 && keyLooksLikeHeader(firstKey) {
+		header = newHeader(0)
+		keysLen += len(header)
+	}
 
 	startOfValues := keysLen
-	bm := make(ByteMap, startOfValues+valuesLen)
-	keyOffset := 0
+	var bm ByteMap
+	if cap(buf) >= startOfValues+valuesLen {
+		// Every byte gets overwritten below
+		bm = buf[:startOfValues+valuesLen]
+	} else {
+		bm = make(ByteMap, startOfValues+valuesLen)
+	}
+	copy(bm, header)
+	keyOffset := len(header)
 	valueOffset := startOfValues
 	finalIterate(func(key string, value interface{}) {
 		keyLen := len(key)
@@ -143,84 +368,349 @@ func Build(iterate func(func(string, interface{})), valueFor func(string) interf
 		}
 	})
 
-	return bm
+	return bm, nil
 }
 
-// Get gets the value for the given key, or nil if the key is not found.
-func (bm ByteMap) Get(key string) interface{} {
-	keyBytes := []byte(key)
-	keyOffset := 0
-	firstValueOffset := 0
-	for {
-		keyLen, ok := bm.uint16At(keyOffset)
-		if !ok {
-			return nil
+// Rebuild builds a new ByteMap from the given map like New, but reuses the
+// backing array of this ByteMap if it has enough capacity, which avoids
+// allocating a new buffer when building a steady stream of similarly sized
+// maps. If the new contents don't fit, a new buffer is allocated and this
+// ByteMap is left untouched.
+//
+// When the backing array is reused, this ByteMap (and anything else sharing
+// its backing array, such as values returned by GetBytes) is overwritten, so
+// it must not be used anymore, including from other goroutines.
+func (bm ByteMap) Rebuild(m map[string]interface{}) ByteMap {
+	return mustBuild(buildInto(bm[:0], nil, func(cb func(string, interface{})) {
+		for key, value := range m {
+			cb(key, value)
 		}
-		keyOffset += SizeKeyLen
-		keysMatch := bm.compareAt(keyOffset, keyBytes)
-		keyOffset += keyLen
-		t, ok := bm.byteAt(keyOffset)
+	}, func(key string) interface{} {
+		return m[key]
+	}, false))
+}
+
+// TrySetInPlace replaces the value of an existing key by encoding the new value
+// directly into this ByteMap's backing array, which only works if the new
+// value encodes to the same number of bytes as the current one (e.g. when
+// updating a counter). The type may change as long as the width matches. It
+// returns false without changing anything if the key is absent or nil, if the
+// widths differ, if the map's format stores values differently from the
+// default format (e.g. big-endian maps) or if other keys share the stored
+// value (see Deduplicate).
+//
+// WARNING: ByteMaps are otherwise immutable, and this breaks that guarantee.
+// Only use it on a ByteMap whose backing array you own exclusively. Anything
+// sharing the backing array (copies of this ByteMap, maps sliced out of the
+// same buffer, values returned by GetBytes and friends, Values and Views)
+// sees the change, and concurrent reads from other goroutines are data races.
+func (bm ByteMap) TrySetInPlace(key string, value interface{}) bool {
+	if bm.format() != defaultFormat {
+		return false
+	}
+	ordered := bm.IsOrdered()
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
 		if !ok {
-			return nil
+			return false
 		}
-		keyOffset += SizeValueType
-		if t == TypeNil {
-			if keysMatch {
-				return nil
-			}
-		} else {
-			valueOffset, ok := bm.uint32At(keyOffset)
-			if !ok {
-				return nil
-			}
-			if firstValueOffset == 0 {
-				firstValueOffset = valueOffset
+		candidate := bm[e.keyStart:e.keyEnd]
+		if string(candidate) == key {
+			if e.t == TypeNil {
+				return false
 			}
-			if keysMatch {
-				return bm.decodeValueAt(valueOffset, t)
+			old := bm.valueBytesAt(e.valueOffset, e.t)
+			n := encodedLength(value)
+			if old == nil || n == 0 || n != len(old) || bm.valueShared(e) {
+				return false
 			}
-			keyOffset += SizeValueOffset
+			bm[e.keyEnd], _ = encodeValue(bm[e.valueOffset:e.valueOffset+n], value)
+			return true
 		}
-		if firstValueOffset > 0 && keyOffset >= firstValueOffset {
-			break
+		if !ordered && string(candidate) > key {
+			return false
 		}
 	}
-	return nil
+}
+
+// valueShared indicates whether any entry other than e points at e's value.
+func (bm ByteMap) valueShared(e entry) bool {
+	c := bm.Cursor()
+	for {
+		other, ok := c.next()
+		if !ok {
+			return false
+		}
+		if other.t != TypeNil && other.valueOffset == e.valueOffset && other.keyStart != e.keyStart {
+			return true
+		}
+	}
+}
+
+// EstimateSize returns the exact length of the ByteMap that New would build
+// from the given map, without building it.
+func EstimateSize(m map[string]interface{}) int {
+	size := 0
+	first, haveFirst := "", false
+	for key, value := range m {
+		keyLen, valueLen := encodedEntryLength(key, value)
+		size += keyLen + valueLen
+		if !haveFirst || key < first {
+			first, haveFirst = key, true
+		}
+	}
+	if haveFirst && keyLooksLikeHeader(first) {
+		// New prefixes a header so that the first key isn't mistaken for one
+		size += SizeHeader
+	}
+	return size
+}
+
+// KeyOverhead returns the number of bytes that the given key occupies in the
+// key region of a ByteMap, including its length, the type of its value and,
+// if hasValue is true, the offset of its value.
+func KeyOverhead(key string, hasValue bool) int {
+	overhead := len(key) + SizeKeyLen + SizeValueType
+	if hasValue {
+		overhead += SizeValueOffset
+	}
+	return overhead
+}
+
+// encodedEntryLength returns the number of bytes that the given key/value pair
+// occupies in the key region and the value region respectively.
+func encodedEntryLength(key string, value interface{}) (keyLen int, valueLen int) {
+	valueLen = encodedLength(value)
+	return KeyOverhead(key, valueLen > 0), valueLen
+}
+
+// Compact returns a ByteMap with the same contents as this one whose capacity
+// equals its length. All of the constructors in this package already return
+// compact maps, but ByteMaps that were sliced out of larger buffers (for example
+// when reading several maps from one network buffer) may pin much more memory
+// than they need, which adds up when holding many of them long-term.
+func (bm ByteMap) Compact() ByteMap {
+	if cap(bm) == len(bm) {
+		return bm
+	}
+	out := make(ByteMap, len(bm))
+	copy(out, bm)
+	return out
+}
+
+// sizeSliceHeader is the size of a slice header (pointer, length and
+// capacity) on 64-bit platforms.
+const sizeSliceHeader = 24
+
+// MemoryFootprint returns the approximate number of bytes of memory that this
+// ByteMap keeps alive. Whereas len(bm) is the size of the encoded map and
+// cap(bm) is the size of the backing array from the start of the map onwards,
+// the footprint is cap(bm) plus the slice header itself. The difference
+// between len and cap matters for ByteMaps that are views into larger arrays,
+// like nested maps returned by Get, which keep the whole array alive. The
+// footprint can't see any part of the array that precedes the view, so it
+// underestimates for such views. Copy a ByteMap (e.g. with Compact) to make
+// its footprint match its length.
+func (bm ByteMap) MemoryFootprint() int {
+	return sizeSliceHeader + cap(bm)
+}
+
+// Get gets the value for the given key, or nil if the key is not found.
+func (bm ByteMap) Get(key string) interface{} {
+	t, valueOffset, found := bm.find(key)
+	if !found || t == TypeNil {
+		return nil
+	}
+	return bm.decodeValueAt(valueOffset, t)
 }
 
 // GetBytes gets the bytes slice for the given key, or nil if the key is not
 // found.
 func (bm ByteMap) GetBytes(key string) []byte {
+	t, valueOffset, found := bm.find(key)
+	if !found || t == TypeNil {
+		return nil
+	}
+	return bm.valueBytesAt(valueOffset, t)
+}
+
+// GetRaw gets the type and value bytes for the given key in a single scan. ok
+// is false if the key is not found. Keys that are present with a nil value
+// return TypeNil, nil value bytes and an ok of true.
+func (bm ByteMap) GetRaw(key string) (t byte, valueBytes []byte, ok bool) {
+	t, valueOffset, found := bm.find(key)
+	if !found {
+		return TypeNil, nil, false
+	}
+	if t == TypeNil {
+		return TypeNil, nil, true
+	}
+	valueBytes = bm.valueBytesAt(valueOffset, t)
+	if valueBytes == nil {
+		return TypeNil, nil, false
+	}
+	return t, valueBytes, true
+}
+
+// GetValueKey returns a comparable string form of the value for the given key,
+// e.g. for grouping maps by that value in a Go map. It consists of the value's
+// type followed by its encoded bytes, so values of different types never
+// produce the same string, even if their bytes are the same. Values are in the
+// default encoding, so equal values produce the same string regardless of the
+// Options that the map was built with. ok is false if the key is not found.
+func (bm ByteMap) GetValueKey(key string) (valueKey string, ok bool) {
+	t, valueBytes, ok := bm.GetRaw(key)
+	if !ok {
+		return "", false
+	}
+	b := make([]byte, 0, 1+len(valueBytes))
+	b = append(b, t)
+	b = append(b, bm.defaultEncoding(t, valueBytes)...)
+	return string(b), true
+}
+
+// ValueReaderFor returns an io.Reader over the value for the given key along
+// with its type, so that large values can be streamed (e.g. with io.Copy)
+// without copying them. For TypeString and TypeBytes values, the reader yields
+// exactly the string's or slice's contents, without the length prefix. For
+// all other types, it yields the same encoded bytes as GetBytes. The reader
+// aliases the underlying ByteMap. ok is false if the key is not found or its
+// value is nil.
+func (bm ByteMap) ValueReaderFor(key string) (r io.Reader, t byte, ok bool) {
+	t, valueBytes, ok := bm.GetRaw(key)
+	if !ok || t == TypeNil {
+		return nil, TypeNil, false
+	}
+	switch t {
+	case TypeString:
+		valueBytes = valueBytes[bm.stringLenWidth():]
+	case TypeBytes:
+		valueBytes = valueBytes[2:]
+	}
+	return bytes.NewReader(valueBytes), t, true
+}
+
+// GetE is like Get but distinguishes between keys that are absent and keys
+// that are present with a nil value, and returns an error if it runs into
+// corruption while looking up the key, e.g. a truncated key, a value offset
+// that's out of range or a string whose length exceeds the map. Unlike
+// Validate, GetE only checks the parts of the map that it reads.
+func (bm ByteMap) GetE(key string) (value interface{}, present bool, err error) {
+	if _, ok := FormatVersion(bm); !ok || bm.headerLen() > len(bm) {
+		return nil, false, fmt.Errorf("bytemap: unknown format")
+	}
+	ordered := bm.IsOrdered()
+	c := Cursor{bm: bm, offset: bm.keysStart(), bigEndian: bm.bigEndian()}
+	for {
+		e, ok := c.next()
+		if !ok {
+			if c.corrupt {
+				return nil, false, fmt.Errorf("bytemap: truncated key at offset %d", c.offset)
+			}
+			return nil, false, nil
+		}
+		candidate := bm[e.keyStart:e.keyEnd]
+		if !ordered && string(candidate) > key {
+			return nil, false, nil
+		}
+		if string(candidate) != key {
+			continue
+		}
+		if e.t == TypeNil {
+			return nil, true, nil
+		}
+		f := bm.format()
+		if err := validateValue(f, e.t, bm.valueBytesIn(f, e.valueOffset, e.t)); err != nil {
+			return nil, true, fmt.Errorf("bytemap: value of %q: %v", key, err)
+		}
+		return bm.decodeValueIn(f, e.valueOffset, e.t), true, nil
+	}
+}
+
+// Has indicates whether the given key is present in this ByteMap (even if its
+// value is nil). It doesn't decode any values or allocate, and unless the map
+// IsOrdered, it stops as soon as it passes the position at which the key would
+// have been stored, which makes misses cheap.
+func (bm ByteMap) Has(key string) bool {
+	ordered := bm.IsOrdered()
+	c := Cursor{bm: bm, offset: bm.keysStart(), bigEndian: bm.bigEndian()}
+	for {
+		e, ok := c.next()
+		if !ok {
+			return false
+		}
+		candidate := bm[e.keyStart:e.keyEnd]
+		if string(candidate) == key {
+			return true
+		}
+		if !ordered && string(candidate) > key {
+			return false
+		}
+	}
+}
+
+// GetTimeIn gets the time value for the given key in the given location. ok is
+// false if the key is not found or doesn't hold a time value. Get returns time
+// values in UTC.
+func (bm ByteMap) GetTimeIn(key string, loc *time.Location) (tm time.Time, ok bool) {
+	t, valueOffset, found := bm.find(key)
+	if !found || t != TypeTime {
+		return time.Time{}, false
+	}
+	nanos, n := bm.timeAt(bm.format(), valueOffset)
+	if n == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos).In(loc), true
+}
+
+// GetUnixNano gets the time value for the given key as nanoseconds since the
+// Unix epoch, which is how it's stored, without constructing a time.Time. ok is
+// false if the key is not found or doesn't hold a time value.
+func (bm ByteMap) GetUnixNano(key string) (nanos int64, ok bool) {
+	t, valueOffset, found := bm.find(key)
+	if !found || t != TypeTime {
+		return 0, false
+	}
+	nanos, n := bm.timeAt(bm.format(), valueOffset)
+	return nanos, n > 0
+}
+
+// find finds the type and value offset for the given key. found is false if
+// the key is not found.
+func (bm ByteMap) find(key string) (t byte, valueOffset int, found bool) {
 	keyBytes := []byte(key)
-	keyOffset := 0
+	keyOffset := bm.keysStart()
+	bigEndian := bm.bigEndian()
 	firstValueOffset := 0
 	for {
-		keyLen, ok := bm.uint16At(keyOffset)
+		keyLen, ok := bm.uint16In(bigEndian, keyOffset)
 		if !ok {
-			return nil
+			return TypeNil, 0, false
 		}
 		keyOffset += SizeKeyLen
-		keysMatch := bm.compareAt(keyOffset, keyBytes)
+		keysMatch := bm.compareAt(keyOffset, keyBytes) && keyLen == len(keyBytes)
 		keyOffset += keyLen
 		t, ok := bm.byteAt(keyOffset)
 		if !ok {
-			return nil
+			return TypeNil, 0, false
 		}
 		keyOffset += SizeValueType
 		if t == TypeNil {
 			if keysMatch {
-				return nil
+				return TypeNil, 0, true
 			}
 		} else {
-			valueOffset, ok := bm.uint32At(keyOffset)
+			valueOffset, ok := bm.uint32In(bigEndian, keyOffset)
 			if !ok {
-				return nil
+				return TypeNil, 0, false
 			}
 			if firstValueOffset == 0 {
 				firstValueOffset = valueOffset
 			}
 			if keysMatch {
-				return bm.valueBytesAt(valueOffset, t)
+				return t, valueOffset, true
 			}
 			keyOffset += SizeValueOffset
 		}
@@ -228,10 +718,12 @@ func (bm ByteMap) GetBytes(key string) []byte {
 			break
 		}
 	}
-	return nil
+	return TypeNil, 0, false
 }
 
-// AsMap returns a map representation of this ByteMap.
+// AsMap returns a map representation of this ByteMap. Keys that are present
+// with a nil value map to nil, so they can only be told apart from absent keys
+// using the comma-ok form of map lookups (see also AsMapWithPresence).
 func (bm ByteMap) AsMap() map[string]interface{} {
 	result := make(map[string]interface{}, 10)
 	bm.IterateValues(func(key string, value interface{}) bool {
@@ -241,6 +733,140 @@ func (bm ByteMap) AsMap() map[string]interface{} {
 	return result
 }
 
+// AsMapWithPresence is like AsMap but also returns a map that is true for every
+// key present in this ByteMap, including keys with nil values. This preserves
+// the distinction between present nil values and absent keys for code that
+// flattens the result into other structures.
+func (bm ByteMap) AsMapWithPresence() (values map[string]interface{}, present map[string]bool) {
+	values = make(map[string]interface{}, 10)
+	present = make(map[string]bool, 10)
+	bm.IterateValues(func(key string, value interface{}) bool {
+		values[key] = value
+		present[key] = true
+		return true
+	})
+	return values, present
+}
+
+// DecodeWhere returns a map of the key/value pairs in this ByteMap for which
+// accept returns true. accept is called with each key and the type of its
+// value before the value is decoded, so values that aren't accepted are
+// skipped without being decoded. This is cheaper than filtering into a new
+// ByteMap and calling AsMap on that.
+func (bm ByteMap) DecodeWhere(accept func(key string, t byte) bool) map[string]interface{} {
+	result := make(map[string]interface{})
+	f := bm.format()
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return result
+		}
+		key := string(bm[e.keyStart:e.keyEnd])
+		if !accept(key, e.t) {
+			continue
+		}
+		var value interface{}
+		if e.t != TypeNil {
+			value = bm.decodeValueIn(f, e.valueOffset, e.t)
+		}
+		result[key] = value
+	}
+}
+
+// Len returns the number of keys in this ByteMap, including keys with nil
+// values.
+func (bm ByteMap) Len() int {
+	n := 0
+	c := bm.Cursor()
+	for {
+		if _, ok := c.next(); !ok {
+			return n
+		}
+		n++
+	}
+}
+
+// Keys returns the keys of this ByteMap in the order in which they're stored
+// (sorted order unless the map IsOrdered).
+func (bm ByteMap) Keys() []string {
+	result := make([]string, 0, bm.Len())
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return result
+		}
+		result = append(result, string(bm[e.keyStart:e.keyEnd]))
+	}
+}
+
+// Values returns the values of this ByteMap in the same order as Keys, with nil
+// for keys that have nil values.
+func (bm ByteMap) Values() []interface{} {
+	result := make([]interface{}, 0, bm.Len())
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return result
+		}
+		var value interface{}
+		if e.t != TypeNil {
+			value = bm.decodeValueAt(e.valueOffset, e.t)
+		}
+		result = append(result, value)
+	}
+}
+
+// Entries returns the key/value pairs of this ByteMap in the same order as
+// Keys, with nil values for keys that have nil values.
+func (bm ByteMap) Entries() []KV {
+	result := make([]KV, 0, bm.Len())
+	bm.IterateValues(func(key string, value interface{}) bool {
+		result = append(result, KV{key, value})
+		return true
+	})
+	return result
+}
+
+// KeyAt returns the key at the given position in this ByteMap (in sorted order
+// unless the map IsOrdered). ok is false if i is out of range.
+func (bm ByteMap) KeyAt(i int) (key string, ok bool) {
+	e, ok := bm.entryAt(i)
+	if !ok {
+		return "", false
+	}
+	return string(bm[e.keyStart:e.keyEnd]), true
+}
+
+// EntryAt returns the key and value at the given position in this ByteMap (in
+// sorted order unless the map IsOrdered). ok is false if i is out of range.
+func (bm ByteMap) EntryAt(i int) (key string, value interface{}, ok bool) {
+	e, ok := bm.entryAt(i)
+	if !ok {
+		return "", nil, false
+	}
+	if e.t != TypeNil {
+		value = bm.decodeValueAt(e.valueOffset, e.t)
+	}
+	return string(bm[e.keyStart:e.keyEnd]), value, true
+}
+
+func (bm ByteMap) entryAt(i int) (e entry, ok bool) {
+	if i < 0 {
+		return e, false
+	}
+	c := bm.Cursor()
+	for j := 0; j <= i; j++ {
+		e, ok = c.next()
+		if !ok {
+			return e, false
+		}
+	}
+	return e, true
+}
+
 // IterateValues iterates over the key/value pairs in this ByteMap and calls the
 // given callback with each. If the callback returns false, iteration stops even
 // if there remain unread values.
@@ -259,71 +885,95 @@ func (bm ByteMap) IterateValueBytes(cb func(key string, valueBytes []byte) bool)
 	})
 }
 
+// IterateOffsets iterates over the keys in this ByteMap and calls the given
+// callback with each key's type and the absolute offset and length of its
+// value within this ByteMap, such that bm[valueOffset:valueOffset+valueLen]
+// are the value's bytes. This allows building external indexes into the
+// values. Keys with nil values have an offset and length of 0. If the callback
+// returns false, iteration stops even if there remain unread values.
+func (bm ByteMap) IterateOffsets(cb func(key string, t byte, valueOffset int, valueLen int) bool) {
+	f := bm.format()
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return
+		}
+		valueLen := 0
+		if e.t != TypeNil {
+			valueBytes := bm.valueBytesIn(f, e.valueOffset, e.t)
+			if valueBytes == nil {
+				return
+			}
+			valueLen = len(valueBytes)
+		}
+		if !cb(string(bm[e.keyStart:e.keyEnd]), e.t, e.valueOffset, valueLen) {
+			return
+		}
+	}
+}
+
 // Iterate iterates over the key/value pairs in this ByteMap and calls the given
 // callback with each. If the callback returns false, iteration stops even if
 // there remain unread values. includeValue and includeBytes determine whether
 // to include the value, the bytes or both in the callback.
 func (bm ByteMap) Iterate(includeValue bool, includeBytes bool, cb func(key string, value interface{}, valueBytes []byte) bool) {
-	if len(bm) == 0 {
-		return
-	}
-
-	keyOffset := 0
-	firstValueOffset := 0
+	f := bm.format()
+	c := bm.Cursor()
 	for {
-		if keyOffset >= len(bm) {
-			break
+		e, ok := c.next()
+		if !ok {
+			return
 		}
-		keyLen := int(enc.Uint16(bm[keyOffset:]))
-		keyOffset += SizeKeyLen
-		key := string(bm[keyOffset : keyOffset+keyLen])
-		keyOffset += keyLen
-		t := bm[keyOffset]
-		keyOffset += SizeValueType
 		var value interface{}
 		var bytes []byte
-		if t != TypeNil {
-			valueOffset := int(enc.Uint32(bm[keyOffset:]))
-			if firstValueOffset == 0 {
-				firstValueOffset = valueOffset
-			}
+		if e.t != TypeNil {
 			if includeValue {
-				value = bm.decodeValueAt(valueOffset, t)
+				value = bm.decodeValueIn(f, e.valueOffset, e.t)
 			}
 			if includeBytes {
-				bytes = bm.valueBytesAt(valueOffset, t)
+				bytes = bm.valueBytesIn(f, e.valueOffset, e.t)
 			}
-			keyOffset += SizeValueOffset
 		}
-		if !cb(key, value, bytes) {
+		if !cb(string(bm[e.keyStart:e.keyEnd]), value, bytes) {
 			// Stop iterating
 			return
 		}
-		if firstValueOffset > 0 && keyOffset >= firstValueOffset {
-			break
-		}
 	}
 }
 
 // Slice creates a new ByteMap that contains only the specified keys from the
 // original.
 func (bm ByteMap) Slice(includeKeys map[string]bool) ByteMap {
-	result, _ := bm.doSplit(false, includeKeys)
+	result, _ := bm.doSplit(true, false, includeKeys)
+	return result
+}
+
+// SliceExcept creates a new ByteMap that contains all keys from the original
+// except for the specified ones. It is equivalent to the second result of
+// Split, but doesn't build the first.
+func (bm ByteMap) SliceExcept(keys ...string) ByteMap {
+	excludeKeys := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		excludeKeys[key] = true
+	}
+	_, result := bm.doSplit(false, true, excludeKeys)
 	return result
 }
 
 // Split returns two byte maps, the first containing all of the specified keys
 // and the second containing all of the other keys.
 func (bm ByteMap) Split(includeKeys map[string]bool) (ByteMap, ByteMap) {
-	return bm.doSplit(true, includeKeys)
+	return bm.doSplit(true, true, includeKeys)
 }
 
-func (bm ByteMap) doSplit(includeOmitted bool, includeKeys map[string]bool) (ByteMap, ByteMap) {
-	matchedKeys := make([][]byte, 0, len(includeKeys))
-	matchedValueOffsets := make([]int, 0, len(includeKeys))
-	matchedValues := make([][]byte, 0, len(includeKeys))
+func (bm ByteMap) doSplit(includeMatched bool, includeOmitted bool, includeKeys map[string]bool) (ByteMap, ByteMap) {
+	var matchedKeys [][]byte
+	var matchedValueOffsets []int
+	var matchedValues [][]byte
 	matchedKeysLen := 0
 	matchedValuesLen := 0
+	matchedCount := 0
 	var omittedKeys [][]byte
 	var omittedValueOffsets []int
 	var omittedValues [][]byte
@@ -334,72 +984,140 @@ func (bm ByteMap) doSplit(includeOmitted bool, includeKeys map[string]bool) (Byt
 		omittedValueOffsets = make([]int, 0, 10)
 		omittedValues = make([][]byte, 0, 10)
 	}
-	keyOffset := 0
-	firstValueOffset := 0
+	header := bm.header()
+	addMatched := func(key []byte, value []byte) {
+		if matchedKeys == nil {
+			matchedKeys = make([][]byte, 0, len(includeKeys))
+			matchedValueOffsets = make([]int, 0, len(includeKeys))
+			matchedValues = make([][]byte, 0, len(includeKeys))
+		}
+		matchedKeys = append(matchedKeys, key)
+		matchedValueOffsets = append(matchedValueOffsets, matchedValuesLen)
+		matchedValues = append(matchedValues, value)
+		matchedKeysLen += len(key) + SizeValueOffset
+		matchedValuesLen += len(value)
+	}
+
+	// As long as the matched entries are contiguous (which is common when
+	// slicing a block of related keys), we only track the range of the source
+	// that they span so that we can copy it wholesale. Once we find that they
+	// aren't contiguous, we fall back to collecting the entries individually.
+	contiguous := includeMatched && !includeOmitted
+	runKeysStart, runKeysEnd := -1, -1
+	runValuesStart, runValuesEnd := -1, -1
 
+	// The IDs of maps built with BuildWithDict never match, but are kept
+	dictKeys := bm.HasDictKeys()
+	f := bm.format()
+	c := bm.entryCursor()
 	for {
-		if keyOffset >= len(bm) {
+		e, ok := c.next()
+		if !ok {
 			break
 		}
-		keyStart := keyOffset
-		keyLen := int(enc.Uint16(bm[keyOffset:]))
-		keyOffset += SizeKeyLen
-		candidate := bm[keyOffset : keyOffset+keyLen]
-		matched := includeKeys[string(candidate)]
-		keyOffset += keyLen
-		t := bm[keyOffset]
-		keyOffset += SizeValueType
-		if t != TypeNil {
-			valueOffset := int(enc.Uint32(bm[keyOffset:]))
-			if firstValueOffset == 0 {
-				firstValueOffset = valueOffset
+		keyStart := e.keyStart - SizeKeyLen
+		matched := !dictKeys && includeKeys[string(bm[e.keyStart:e.keyEnd])]
+		if e.t != TypeNil {
+			keyEnd := e.keyEnd + SizeValueType
+			value := bm.valueBytesIn(f, e.valueOffset, e.t)
+			if value == nil {
+				// Value is out of bounds, stop here
+				break
 			}
-			valueLen := bm.lengthOf(valueOffset, t)
-			value := bm[valueOffset : valueOffset+valueLen]
-
-			if matched {
-				matchedKeys = append(matchedKeys, bm[keyStart:keyOffset])
-				matchedValueOffsets = append(matchedValueOffsets, matchedValuesLen)
-				matchedValues = append(matchedValues, value)
-				matchedKeysLen += keyOffset + SizeValueOffset - keyStart
-				matchedValuesLen += valueLen
-			} else if includeOmitted {
-				omittedKeys = append(omittedKeys, bm[keyStart:keyOffset])
+			valueLen := len(value)
+
+			if matched && includeMatched {
+				matchedCount++
+				if contiguous && runKeysStart < 0 {
+					runKeysStart, runValuesStart = keyStart, e.valueOffset
+					runKeysEnd, runValuesEnd = keyEnd+SizeValueOffset, e.valueOffset+valueLen
+				} else if contiguous && keyStart == runKeysEnd && e.valueOffset == runValuesEnd {
+					runKeysEnd, runValuesEnd = keyEnd+SizeValueOffset, e.valueOffset+valueLen
+				} else {
+					if contiguous {
+						contiguous = false
+						bm.iterateRun(runKeysStart, runKeysEnd, addMatched)
+					}
+					addMatched(bm[keyStart:keyEnd], value)
+				}
+			} else if !matched && includeOmitted {
+				omittedKeys = append(omittedKeys, bm[keyStart:keyEnd])
 				omittedValueOffsets = append(omittedValueOffsets, omittedValuesLen)
 				omittedValues = append(omittedValues, value)
-				omittedKeysLen += keyOffset + SizeValueOffset - keyStart
+				omittedKeysLen += keyEnd + SizeValueOffset - keyStart
 				omittedValuesLen += valueLen
 			}
-
-			keyOffset += SizeValueOffset
-		}
-
-		if keyOffset >= firstValueOffset {
-			break
 		}
 
-		if !includeOmitted && len(matchedKeys) == len(includeKeys) {
+		if !includeOmitted && matchedCount == len(includeKeys) {
 			break
 		}
 	}
 
-	included := buildFromSliced(matchedKeysLen, matchedValuesLen, matchedKeys, matchedValueOffsets, matchedValues)
+	var included ByteMap
+	if contiguous && runKeysStart >= 0 {
+		included = buildFromRun(header, bm[runKeysStart:runKeysEnd], bm[runValuesStart:runValuesEnd], runValuesStart)
+	} else if includeMatched {
+		included = buildFromSliced(header, matchedKeysLen, matchedValuesLen, matchedKeys, matchedValueOffsets, matchedValues)
+	}
 	var omitted ByteMap
 	if includeOmitted {
-		omitted = buildFromSliced(omittedKeysLen, omittedValuesLen, omittedKeys, omittedValueOffsets, omittedValues)
+		omitted = buildFromSliced(header, omittedKeysLen, omittedValuesLen, omittedKeys, omittedValueOffsets, omittedValues)
 	}
 	return included, omitted
 }
 
-func buildFromSliced(keysLen int, valuesLen int, keys [][]byte, valueOffsets []int, values [][]byte) ByteMap {
+// iterateRun calls cb with the key bytes (excluding the value offset) and
+// value bytes of each entry between runStart and runEnd, all of which must
+// have values.
+func (bm ByteMap) iterateRun(runStart int, runEnd int, cb func(key []byte, value []byte)) {
+	c := &Cursor{bm: bm, offset: runStart, bigEndian: bm.bigEndian()}
+	for c.offset < runEnd {
+		e, ok := c.next()
+		if !ok {
+			return
+		}
+		cb(bm[e.keyStart-SizeKeyLen:e.keyEnd+SizeValueType], bm.valueBytesAt(e.valueOffset, e.t))
+	}
+}
+
+// buildFromRun builds a ByteMap from a contiguous run of key entries and the
+// contiguous run of values to which they point, which started at valuesStart
+// in the source map.
+func buildFromRun(header []byte, keys []byte, values []byte, valuesStart int) ByteMap {
+	keysLen := len(header) + len(keys)
+	out := make(ByteMap, keysLen+len(values))
+	copy(out, header)
+	copy(out[len(header):], keys)
+	copy(out[keysLen:], values)
+
+	// Rebase the value offsets onto the new map
+	offset := len(header)
+	for offset < keysLen {
+		keyLen, _ := out.uint16At(offset)
+		offset += SizeKeyLen + keyLen
+		t := out[offset]
+		offset += SizeValueType
+		if t != TypeNil {
+			valueOffset, _ := out.uint32At(offset)
+			out.putUint32At(offset, uint32(valueOffset-valuesStart+keysLen))
+			offset += SizeValueOffset
+		}
+	}
+	return out
+}
+
+func buildFromSliced(header []byte, keysLen int, valuesLen int, keys [][]byte, valueOffsets []int, values [][]byte) ByteMap {
+	keysLen += len(header)
 	out := make(ByteMap, keysLen+valuesLen)
-	offset := 0
+	copy(out, header)
+	offset := len(header)
 	for i, kb := range keys {
 		valueOffset := valueOffsets[i]
 		copy(out[offset:], kb)
 		offset += len(kb)
 		if valueOffset >= 0 {
-			enc.PutUint32(out[offset:], uint32(valueOffset+keysLen))
+			out.putUint32At(offset, uint32(valueOffset+keysLen))
 			offset += SizeValueOffset
 		}
 	}
@@ -475,14 +1193,48 @@ func encodeValue(slice []byte, value interface{}) (byte, int) {
 		enc.PutUint16(slice, uint16(len(v)))
 		copy(slice[2:], v)
 		return TypeBytes, len(v) + 2
+	case []int8:
+		enc.PutUint16(slice, uint16(len(v)))
+		for i, b := range v {
+			slice[2+i] = byte(b)
+		}
+		return TypeInt8s, len(v) + 2
+	case ByteMap:
+		enc.PutUint32(slice, uint32(len(v)))
+		copy(slice[4:], v)
+		return TypeByteMap, len(v) + 4
+	case []string:
+		offset := 2
+		for _, str := range v {
+			enc.PutUint16(slice[offset:], uint16(len(str)))
+			offset += 2
+			offset += copy(slice[offset:], str)
+		}
+		enc.PutUint16(slice, uint16(offset-2))
+		return TypeStrings, offset
+	case *big.Int:
+		return encodeGob(slice, TypeBigInt, v)
+	case *big.Float:
+		return encodeGob(slice, TypeBigFloat, v)
 	case time.Time:
 		enc.PutUint64(slice, uint64(v.UnixNano()))
 		return TypeTime, 8
 	}
+	if ut := userTypeFor(value); ut != nil {
+		n := ut.encode(slice[2:], value)
+		enc.PutUint16(slice, uint16(n))
+		return ut.tag, n + 2
+	}
 	return TypeNil, 0
 }
 
 func (bm ByteMap) decodeValueAt(offset int, t byte) interface{} {
+	return bm.decodeValueIn(bm.format(), offset, t)
+}
+
+// decodeValueIn decodes the value of type t at the given offset, assuming that
+// it's encoded in the given format.
+func (bm ByteMap) decodeValueIn(f format, offset int, t byte) interface{} {
 	switch t {
 	case TypeBool:
 		if bm.offsetTooHigh(offset, 1) {
@@ -498,22 +1250,22 @@ func (bm ByteMap) decodeValueAt(offset int, t byte) interface{} {
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		return enc.Uint16(bm[offset:])
+		return f.uint16(bm[offset:])
 	case TypeUInt32:
 		if bm.offsetTooHigh(offset, 4) {
 			return nil
 		}
-		return enc.Uint32(bm[offset:])
+		return f.uint32(bm[offset:])
 	case TypeUInt64:
 		if bm.offsetTooHigh(offset, 8) {
 			return nil
 		}
-		return enc.Uint64(bm[offset:])
+		return f.uint64(bm[offset:])
 	case TypeUInt:
 		if bm.offsetTooHigh(offset, 8) {
 			return nil
 		}
-		return uint(enc.Uint64(bm[offset:]))
+		return uint(f.uint64(bm[offset:]))
 	case TypeInt8:
 		if bm.offsetTooHigh(offset, 1) {
 			return nil
@@ -523,88 +1275,186 @@ func (bm ByteMap) decodeValueAt(offset int, t byte) interface{} {
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		return int16(enc.Uint16(bm[offset:]))
+		return int16(f.uint16(bm[offset:]))
 	case TypeInt32:
 		if bm.offsetTooHigh(offset, 4) {
 			return nil
 		}
-		return int32(enc.Uint32(bm[offset:]))
+		return int32(f.uint32(bm[offset:]))
 	case TypeInt64:
 		if bm.offsetTooHigh(offset, 8) {
 			return nil
 		}
-		return int64(enc.Uint64(bm[offset:]))
+		return int64(f.uint64(bm[offset:]))
 	case TypeInt:
 		if bm.offsetTooHigh(offset, 8) {
 			return nil
 		}
-		return int(enc.Uint64(bm[offset:]))
+		return int(f.uint64(bm[offset:]))
 	case TypeInts:
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		l := int(enc.Uint16(bm[offset:]))
+		l := int(f.uint16(bm[offset:]))
 		if bm.offsetTooHigh(offset+2, l*8) {
 			return nil
 		}
 		result := make([]int, l)
 		for i := 0; i < l; i++ {
-			result[i] = int(enc.Uint64(bm[offset+2+i*8:]))
+			result[i] = int(f.uint64(bm[offset+2+i*8:]))
 		}
 		return result
 	case TypeFloat32:
 		if bm.offsetTooHigh(offset, 4) {
 			return nil
 		}
-		return math.Float32frombits(enc.Uint32(bm[offset:]))
+		return math.Float32frombits(f.uint32(bm[offset:]))
 	case TypeFloat64:
 		if bm.offsetTooHigh(offset, 8) {
 			return nil
 		}
-		return math.Float64frombits(enc.Uint64(bm[offset:]))
+		return math.Float64frombits(f.uint64(bm[offset:]))
 	case TypeFloat64s:
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		l := int(enc.Uint16(bm[offset:]))
+		l := int(f.uint16(bm[offset:]))
 		if bm.offsetTooHigh(offset+2, l*8) {
 			return nil
 		}
 		result := make([]float64, l)
 		for i := 0; i < l; i++ {
-			result[i] = math.Float64frombits(enc.Uint64(bm[offset+2+i*8:]))
+			result[i] = math.Float64frombits(f.uint64(bm[offset+2+i*8:]))
 		}
 		return result
 	case TypeString:
+		w := f.strLenWidth
+		l, ok := bm.stringLenAt(f, offset)
+		if !ok || bm.offsetTooHigh(offset+w, l) {
+			return nil
+		}
+		return string(bm[offset+w : offset+w+l])
+	case TypeBytes:
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		l := int(enc.Uint16(bm[offset:]))
+		l := int(f.uint16(bm[offset:]))
 		if bm.offsetTooHigh(offset+2, l) {
 			return nil
 		}
-		return string(bm[offset+2 : offset+2+l])
-	case TypeBytes:
+		return []byte(bm[offset+2 : offset+2+l])
+	case TypeInt8s:
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		l := int(enc.Uint16(bm[offset:]))
+		l := int(f.uint16(bm[offset:]))
 		if bm.offsetTooHigh(offset+2, l) {
 			return nil
 		}
-		return []byte(bm[offset+2 : offset+2+l])
+		result := make([]int8, l)
+		for i := 0; i < l; i++ {
+			result[i] = int8(bm[offset+2+i])
+		}
+		return result
+	case TypeBigInt, TypeBigFloat:
+		if bm.offsetTooHigh(offset, 4) {
+			return nil
+		}
+		l := int(f.uint32(bm[offset:]))
+		if bm.offsetTooHigh(offset+4, l) {
+			return nil
+		}
+		return decodeGob(t, bm[offset+4:offset+4+l])
+	case TypeStrings:
+		if bm.offsetTooHigh(offset, 2) {
+			return nil
+		}
+		l := int(f.uint16(bm[offset:]))
+		if bm.offsetTooHigh(offset+2, l) {
+			return nil
+		}
+		return decodeStrings(f, bm[offset+2:offset+2+l])
+	case TypeByteMap:
+		if bm.offsetTooHigh(offset, 4) {
+			return nil
+		}
+		l := int(f.uint32(bm[offset:]))
+		if bm.offsetTooHigh(offset+4, l) {
+			return nil
+		}
+		return bm[offset+4 : offset+4+l]
+	case TypeCompressed:
+		if bm.offsetTooHigh(offset, 4) {
+			return nil
+		}
+		l := int(f.uint32(bm[offset:]))
+		if bm.offsetTooHigh(offset+4, l) {
+			return nil
+		}
+		return decodeCompressed(f, bm[offset+4:offset+4+l])
+	case TypeProto:
+		if bm.offsetTooHigh(offset, 4) {
+			return nil
+		}
+		l := int(f.uint32(bm[offset:]))
+		if bm.offsetTooHigh(offset+4, l) {
+			return nil
+		}
+		return []byte(bm[offset+4 : offset+4+l])
+	case TypeJSON:
+		if bm.offsetTooHigh(offset, 4) {
+			return nil
+		}
+		l := int(f.uint32(bm[offset:]))
+		if bm.offsetTooHigh(offset+4, l) {
+			return nil
+		}
+		return decodeJSON(bm[offset+4 : offset+4+l])
 	case TypeTime:
-		if bm.offsetTooHigh(offset, 8) {
+		nanos, n := bm.timeAt(f, offset)
+		if n == 0 {
+			return nil
+		}
+		return time.Unix(0, nanos).UTC()
+	}
+	if ut := userTypeForTag(t); ut != nil {
+		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		nanos := int64(enc.Uint64(bm[offset:]))
-		second := int64(time.Second)
-		return time.Unix(nanos/second, nanos%second)
+		l := int(f.uint16(bm[offset:]))
+		if bm.offsetTooHigh(offset+2, l) {
+			return nil
+		}
+		return ut.decode(bm[offset+2 : offset+2+l])
 	}
 	return nil
 }
 
+// decodeStrings decodes the length prefixed strings that make up the body of a
+// TypeStrings value, returning nil if any of them runs past the end of b.
+func decodeStrings(f format, b []byte) []string {
+	result := make([]string, 0, 4)
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil
+		}
+		l := int(f.uint16(b))
+		if len(b) < 2+l {
+			return nil
+		}
+		result = append(result, string(b[2:2+l]))
+		b = b[2+l:]
+	}
+	return result
+}
+
 func (bm ByteMap) valueBytesAt(offset int, t byte) []byte {
+	return bm.valueBytesIn(bm.format(), offset, t)
+}
+
+// valueBytesIn returns the bytes of the value of type t at the given offset,
+// assuming that it's encoded in the given format.
+func (bm ByteMap) valueBytesIn(f format, offset int, t byte) []byte {
 	switch t {
 	case TypeBool, TypeByte, TypeInt8:
 		if bm.offsetTooHigh(offset, 1) {
@@ -621,16 +1471,31 @@ func (bm ByteMap) valueBytesAt(offset int, t byte) []byte {
 			return nil
 		}
 		return bm[offset : offset+4]
-	case TypeUInt64, TypeUInt, TypeInt64, TypeInt, TypeFloat64, TypeTime:
+	case TypeUInt64, TypeUInt, TypeInt64, TypeInt, TypeFloat64:
 		if bm.offsetTooHigh(offset, 8) {
 			return nil
 		}
 		return bm[offset : offset+8]
+	case TypeByteMap, TypeCompressed, TypeProto, TypeJSON:
+		if bm.offsetTooHigh(offset, 4) {
+			return nil
+		}
+		l := int(f.uint32(bm[offset:]))
+		if bm.offsetTooHigh(offset+4, l) {
+			return nil
+		}
+		return bm[offset : offset+4+l]
+	case TypeTime:
+		_, n := bm.timeAt(f, offset)
+		if n == 0 {
+			return nil
+		}
+		return bm[offset : offset+n]
 	case TypeInts:
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		l := int(enc.Uint16(bm[offset:]))
+		l := int(f.uint16(bm[offset:]))
 		if bm.offsetTooHigh(offset+2, l*8) {
 			return nil
 		}
@@ -639,16 +1504,42 @@ func (bm ByteMap) valueBytesAt(offset int, t byte) []byte {
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		l := int(enc.Uint16(bm[offset:]))
+		l := int(f.uint16(bm[offset:]))
 		if bm.offsetTooHigh(offset+2, l*8) {
 			return nil
 		}
 		return bm[offset : offset+2+l*8]
-	case TypeString, TypeBytes:
+	case TypeString:
+		w := f.strLenWidth
+		l, ok := bm.stringLenAt(f, offset)
+		if !ok || bm.offsetTooHigh(offset+w, l) {
+			return nil
+		}
+		return bm[offset : offset+w+l]
+	case TypeBigInt, TypeBigFloat:
+		if bm.offsetTooHigh(offset, 4) {
+			return nil
+		}
+		l := int(f.uint32(bm[offset:]))
+		if bm.offsetTooHigh(offset+4, l) {
+			return nil
+		}
+		return bm[offset : offset+4+l]
+	case TypeBytes, TypeInt8s, TypeStrings:
+		if bm.offsetTooHigh(offset, 2) {
+			return nil
+		}
+		l := int(f.uint16(bm[offset:]))
+		if bm.offsetTooHigh(offset+2, l) {
+			return nil
+		}
+		return bm[offset : offset+2+l]
+	}
+	if t >= TypeUserMin {
 		if bm.offsetTooHigh(offset, 2) {
 			return nil
 		}
-		l := int(enc.Uint16(bm[offset:]))
+		l := int(f.uint16(bm[offset:]))
 		if bm.offsetTooHigh(offset+2, l) {
 			return nil
 		}
@@ -675,24 +1566,23 @@ func encodedLength(value interface{}) int {
 		return len(v) + 2
 	case []byte:
 		return len(v) + 2
+	case []int8:
+		return len(v) + 2
+	case ByteMap:
+		return len(v) + 4
+	case []string:
+		l := 2
+		for _, str := range v {
+			l += len(str) + 2
+		}
+		return l
+	case *big.Int:
+		return gobLength(v)
+	case *big.Float:
+		return gobLength(v)
 	}
-	return 0
-}
-
-func (bm ByteMap) lengthOf(valueOffset int, t byte) int {
-	switch t {
-	case TypeBool, TypeByte, TypeInt8:
-		return 1
-	case TypeUInt16, TypeInt16:
-		return 2
-	case TypeUInt32, TypeInt32, TypeFloat32:
-		return 4
-	case TypeUInt64, TypeInt64, TypeUInt, TypeInt, TypeFloat64, TypeTime:
-		return 8
-	case TypeInts, TypeFloat64s:
-		return int(enc.Uint16(bm[valueOffset:]))*8 + 2
-	case TypeString, TypeBytes:
-		return int(enc.Uint16(bm[valueOffset:])) + 2
+	if ut := userTypeFor(value); ut != nil {
+		return ut.length(value) + 2
 	}
 	return 0
 }
@@ -705,17 +1595,45 @@ func (bm ByteMap) byteAt(offset int) (b byte, ok bool) {
 }
 
 func (bm ByteMap) uint16At(offset int) (result int, ok bool) {
+	return bm.uint16In(bm.bigEndian(), offset)
+}
+
+func (bm ByteMap) uint32At(offset int) (result int, ok bool) {
+	return bm.uint32In(bm.bigEndian(), offset)
+}
+
+// uint16In is like uint16At, but takes the byte order from the caller, which
+// saves looking it up on every read when walking the key region.
+func (bm ByteMap) uint16In(bigEndian bool, offset int) (result int, ok bool) {
 	if bm.offsetTooHigh(offset, 2) {
 		return 0, false
 	}
-	return int(enc.Uint16(bm[offset:])), true
+	v := enc.Uint16(bm[offset:])
+	if bigEndian {
+		v = bits.ReverseBytes16(v)
+	}
+	return int(v), true
 }
 
-func (bm ByteMap) uint32At(offset int) (result int, ok bool) {
+// uint32In is like uint32At, but takes the byte order from the caller.
+func (bm ByteMap) uint32In(bigEndian bool, offset int) (result int, ok bool) {
 	if bm.offsetTooHigh(offset, 4) {
 		return 0, false
 	}
-	return int(enc.Uint32(bm[offset:])), true
+	v := enc.Uint32(bm[offset:])
+	if bigEndian {
+		v = bits.ReverseBytes32(v)
+	}
+	return int(v), true
+}
+
+// putUint32At writes v at the given offset in this ByteMap's byte order.
+func (bm ByteMap) putUint32At(offset int, v uint32) {
+	if bm.bigEndian() {
+		binary.BigEndian.PutUint32(bm[offset:], v)
+		return
+	}
+	enc.PutUint32(bm[offset:], v)
 }
 
 func (bm ByteMap) compareAt(offset int, expected []byte) bool {
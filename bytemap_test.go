@@ -3,8 +3,13 @@ package bytemap
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"math"
+	"math/big"
+	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -31,7 +36,8 @@ var (
 		"float64s": []float64{math.MaxFloat64, -1 * math.MaxFloat64, 0},
 		"string":   "Hello World",
 		"bytes":    []byte{7, 2, 7, 9, 122},
-		"time":     time.Date(2014, 02, 05, 17, 6, 3, 9, time.Local),
+		"int8s":    []int8{math.MinInt8, -1, 0, 1, math.MaxInt8},
+		"time":     time.Date(2014, 02, 05, 17, 6, 3, 9, time.UTC),
 		"nil":      nil,
 	}
 
@@ -70,6 +76,333 @@ func TestGet(t *testing.T) {
 	}
 }
 
+func TestHas(t *testing.T) {
+	bm := New(m)
+	for key := range m {
+		assert.True(t, bm.Has(key), key)
+	}
+	for _, key := range []string{"", "a", "boo", "boolean", "zzz", "int6"} {
+		assert.False(t, bm.Has(key), key)
+	}
+	assert.False(t, ByteMap(nil).Has("a"))
+	assert.Zero(t, testing.AllocsPerRun(100, func() { bm.Has("string") }))
+
+	ordered := BuildOrdered([]KV{{"z", 1}, {"a", nil}})
+	assert.True(t, ordered.Has("a"))
+	assert.True(t, ordered.Has("z"))
+	assert.False(t, ordered.Has("b"))
+}
+
+func TestGetTimeIn(t *testing.T) {
+	expected := time.Date(2014, 02, 05, 17, 6, 3, 9, time.UTC)
+	bm := New(map[string]interface{}{"time": expected.In(time.Local), "string": "x"})
+	assert.Equal(t, expected, bm.Get("time"), "Get should return UTC")
+
+	tm, ok := bm.GetTimeIn("time", time.UTC)
+	assert.True(t, ok)
+	assert.Equal(t, expected, tm)
+
+	loc, err := time.LoadLocation("America/New_York")
+	if assert.NoError(t, err) {
+		tm, ok = bm.GetTimeIn("time", loc)
+		assert.True(t, ok)
+		assert.True(t, expected.Equal(tm))
+		assert.Equal(t, loc, tm.Location())
+		assert.Equal(t, 12, tm.Hour())
+	}
+
+	_, ok = bm.GetTimeIn("string", time.UTC)
+	assert.False(t, ok)
+	_, ok = bm.GetTimeIn("missing", time.UTC)
+	assert.False(t, ok)
+}
+
+func TestGetUnixNano(t *testing.T) {
+	expected := time.Date(2014, 02, 05, 17, 6, 3, 9, time.UTC)
+	bm := New(map[string]interface{}{"time": expected, "int": int64(5), "string": "x"})
+	nanos, ok := bm.GetUnixNano("time")
+	assert.True(t, ok)
+	assert.Equal(t, expected.UnixNano(), nanos)
+
+	delta, err := NewWithOptions(map[string]interface{}{"a": expected, "b": expected.Add(time.Second)}, Options{DeltaTimes: true})
+	if assert.NoError(t, err) {
+		nanos, ok = delta.GetUnixNano("b")
+		assert.True(t, ok)
+		assert.Equal(t, expected.Add(time.Second).UnixNano(), nanos)
+	}
+
+	_, ok = bm.GetUnixNano("int")
+	assert.False(t, ok, "non-time values should not be read as times")
+	_, ok = bm.GetUnixNano("string")
+	assert.False(t, ok)
+	_, ok = bm.GetUnixNano("missing")
+	assert.False(t, ok)
+}
+
+func TestGetRaw(t *testing.T) {
+	bm := New(m)
+	for key, value := range m {
+		typ, valueBytes, ok := bm.GetRaw(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, bm.GetBytes(key), valueBytes, key)
+		expectedType, _ := encodeValue(make([]byte, 100), value)
+		assert.Equal(t, expectedType, typ, key)
+	}
+
+	typ, valueBytes, ok := bm.GetRaw("nil")
+	assert.True(t, ok, "Present nil should be found")
+	assert.EqualValues(t, TypeNil, typ)
+	assert.Nil(t, valueBytes)
+
+	_, _, ok = bm.GetRaw("unspecified")
+	assert.False(t, ok, "Absent key should not be found")
+	_, _, ok = bm.GetRaw("in")
+	assert.False(t, ok, "Prefix of a key should not be found")
+	assert.Nil(t, bm.Get("in"))
+}
+
+func TestGetValueKey(t *testing.T) {
+	// "abcdefgh" has the same 8 bytes as this int64
+	bm := New(map[string]interface{}{
+		"int":    int64(0x6867666564636261),
+		"bytes":  []byte("abcdefgh"),
+		"string": "abcdefgh",
+		"same":   "abcdefgh",
+		"nil":    nil,
+	})
+	assert.Equal(t, "abcdefgh", string(bm.GetBytes("int")))
+
+	intKey, ok := bm.GetValueKey("int")
+	assert.True(t, ok)
+	stringKey, _ := bm.GetValueKey("string")
+	bytesKey, _ := bm.GetValueKey("bytes")
+	sameKey, _ := bm.GetValueKey("same")
+	assert.NotEqual(t, intKey, stringKey, "different types should not collide")
+	assert.NotEqual(t, stringKey, bytesKey, "different types should not collide")
+	assert.Equal(t, stringKey, sameKey)
+
+	nilKey, ok := bm.GetValueKey("nil")
+	assert.True(t, ok)
+	assert.NotEqual(t, "", nilKey)
+	_, ok = bm.GetValueKey("missing")
+	assert.False(t, ok)
+
+	narrow, err := NewWithOptions(map[string]interface{}{"string": "abcdefgh"}, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		narrowKey, _ := narrow.GetValueKey("string")
+		assert.Equal(t, stringKey, narrowKey, "value keys should not depend on format")
+	}
+}
+
+func TestValueReaderFor(t *testing.T) {
+	long := strings.Repeat("long string ", 1000)
+	blob := bytes.Repeat([]byte{1, 2, 3}, 1000)
+	bm := New(map[string]interface{}{"a": long, "b": blob, "c": 5, "d": nil, "e": "after"})
+
+	r, typ, ok := bm.ValueReaderFor("a")
+	if assert.True(t, ok) {
+		assert.EqualValues(t, TypeString, typ)
+		var buf bytes.Buffer
+		n, err := io.Copy(&buf, r)
+		assert.NoError(t, err)
+		assert.EqualValues(t, len(long), n, "reader should stop at the end of the value")
+		assert.Equal(t, long, buf.String())
+	}
+
+	r, typ, ok = bm.ValueReaderFor("b")
+	if assert.True(t, ok) {
+		assert.EqualValues(t, TypeBytes, typ)
+		read, err := io.ReadAll(r)
+		assert.NoError(t, err)
+		assert.Equal(t, blob, read)
+	}
+
+	r, typ, ok = bm.ValueReaderFor("c")
+	if assert.True(t, ok) {
+		assert.EqualValues(t, TypeInt, typ)
+		read, _ := io.ReadAll(r)
+		assert.Equal(t, bm.GetBytes("c"), read)
+	}
+
+	narrow, err := NewWithOptions(map[string]interface{}{"a": "abc", "b": "d"}, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		r, _, ok = narrow.ValueReaderFor("a")
+		if assert.True(t, ok) {
+			read, _ := io.ReadAll(r)
+			assert.Equal(t, "abc", string(read))
+		}
+	}
+
+	_, _, ok = bm.ValueReaderFor("d")
+	assert.False(t, ok, "nil values should have no reader")
+	_, _, ok = bm.ValueReaderFor("missing")
+	assert.False(t, ok)
+}
+
+func TestDecodeWhere(t *testing.T) {
+	testCountedDecodes = 0
+	bm := New(map[string]interface{}{"a": testCounted("a"), "b": 2, "c": testCounted("c"), "d": nil, "e": testCounted("e")})
+	onlyInts := bm.DecodeWhere(func(key string, t byte) bool {
+		return t == TypeInt
+	})
+	assert.Equal(t, map[string]interface{}{"b": 2}, onlyInts)
+	assert.Zero(t, testCountedDecodes, "only accepted values should be decoded")
+
+	byKey := bm.DecodeWhere(func(key string, t byte) bool {
+		return key >= "c" && key < "e"
+	})
+	assert.Equal(t, map[string]interface{}{"c": testCounted("c"), "d": nil}, byKey)
+	assert.Equal(t, 1, testCountedDecodes)
+}
+
+func TestBuildFiltered(t *testing.T) {
+	valueForCalls := make(map[string]int)
+	bm := BuildFiltered(func(cb func(string, interface{})) {
+		for key, value := range m {
+			cb(key, value)
+		}
+	}, func(key string) interface{} {
+		valueForCalls[key]++
+		return m[key]
+	}, func(key string) bool {
+		return strings.HasPrefix(key, "int")
+	})
+
+	expected := make(map[string]interface{})
+	for key, value := range m {
+		if strings.HasPrefix(key, "int") {
+			expected[key] = value
+		}
+	}
+	assert.Equal(t, New(expected), bm)
+	assert.Len(t, valueForCalls, len(expected), "valueFor should only be called for included keys")
+	for key, calls := range valueForCalls {
+		assert.True(t, strings.HasPrefix(key, "int"), key)
+		assert.Equal(t, 1, calls, key)
+	}
+}
+
+func TestGetE(t *testing.T) {
+	bm := New(m)
+	for key, expected := range m {
+		value, present, err := bm.GetE(key)
+		assert.NoError(t, err, key)
+		assert.True(t, present, key)
+		assert.Equal(t, expected, value, key)
+	}
+	for _, key := range []string{"", "a", "in", "zzz"} {
+		value, present, err := bm.GetE(key)
+		assert.NoError(t, err, key)
+		assert.False(t, present, key)
+		assert.Nil(t, value, key)
+	}
+	_, present, err := ByteMap(nil).GetE("a")
+	assert.NoError(t, err)
+	assert.False(t, present)
+
+	// Bad string length
+	corrupted := New(m)
+	_, valueOffset, _ := corrupted.find("string")
+	enc.PutUint16(corrupted[valueOffset:], 0xFFFF)
+	_, present, err = corrupted.GetE("string")
+	assert.True(t, present)
+	assert.Error(t, err)
+	assert.Nil(t, corrupted.Get("string"))
+	_, _, err = corrupted.GetE("int")
+	assert.NoError(t, err, "other keys should still be readable")
+
+	// Out of range value offset
+	corrupted = New(map[string]interface{}{"a": "a"})
+	enc.PutUint32(corrupted[SizeKeyLen+1+SizeValueType:], uint32(len(corrupted)))
+	_, present, err = corrupted.GetE("a")
+	assert.True(t, present)
+	assert.Error(t, err)
+
+	// Truncated key
+	_, _, err = bm[:SizeKeyLen+2].GetE("zzz")
+	assert.Error(t, err)
+}
+
+func TestInt8s(t *testing.T) {
+	values := []int8{-128, -7, 0, 7, 127}
+	bm := New(map[string]interface{}{"int8s": values, "bytes": []byte{1}})
+	assert.Equal(t, values, bm.Get("int8s"))
+	assert.Equal(t, []byte{5, 0, 0x80, 0xf9, 0, 7, 0x7f}, bm.GetBytes("int8s"))
+	assert.Equal(t, []byte{1}, bm.Get("bytes"), "[]byte should still decode as []byte")
+}
+
+func TestStrings(t *testing.T) {
+	values := []string{"a", "", "bcd"}
+	bm := New(map[string]interface{}{"strings": values, "empty": []string{}})
+	assert.Equal(t, values, bm.Get("strings"))
+	assert.Equal(t, []string{}, bm.Get("empty"))
+	assert.Equal(t, []byte{10, 0, 1, 0, 'a', 0, 0, 3, 0, 'b', 'c', 'd'}, bm.GetBytes("strings"))
+
+	// Corrupt the length of the last string
+	_, valueOffset, _ := bm.find("strings")
+	bm[valueOffset+7] = 4
+	assert.Nil(t, bm.Get("strings"))
+	assert.Error(t, bm.Validate())
+}
+
+func TestNested(t *testing.T) {
+	inner := New(map[string]interface{}{"a": 1, "b": "b"})
+	bm := New(map[string]interface{}{"inner": inner, "array": NewArray([]interface{}{inner}), "c": 2})
+	assert.NoError(t, bm.Validate())
+	assert.Equal(t, inner, bm.Get("inner"))
+	assert.Equal(t, []interface{}{inner}, bm.Get("array").(ByteMap).AsSlice())
+	assert.Equal(t, 2, bm.Get("c"))
+
+	// Corrupt the nested map
+	_, valueOffset, _ := bm.find("inner")
+	bm[valueOffset+4] = 0xFF
+	assert.Error(t, bm.Validate())
+}
+
+func TestAsMapPreservesTypes(t *testing.T) {
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"bool", true},
+		{"byte", byte(1)},
+		{"uint16", uint16(2)},
+		{"uint32", uint32(3)},
+		{"uint64", uint64(4)},
+		{"uint", uint(5)},
+		{"int8", int8(-6)},
+		{"int16", int16(-7)},
+		{"int32", int32(-8)},
+		{"int64", int64(-9)},
+		{"int", -10},
+		{"float32", float32(1.5)},
+		{"float64", 2.5},
+		{"string", "string"},
+		{"time", time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)},
+		{"bytes", []byte{1, 2}},
+		{"float64s", []float64{1.5, 2.5}},
+		{"ints", []int{1, -2}},
+		{"int8s", []int8{1, -2}},
+		{"strings", []string{"a", "b"}},
+		{"bigint", big.NewInt(-11)},
+		{"bigfloat", big.NewFloat(12.5)},
+		{"bytemap", New(map[string]interface{}{"a": 1})},
+		{"empty bytes", []byte{}},
+		{"empty strings", []string{}},
+	}
+	input := make(map[string]interface{}, len(tests))
+	for _, test := range tests {
+		input[test.name] = test.value
+	}
+	asMap := New(input).AsMap()
+	for _, test := range tests {
+		actual := asMap[test.name]
+		assert.Equal(t, reflect.TypeOf(test.value), reflect.TypeOf(actual), test.name)
+		assert.Equal(t, test.value, actual, test.name)
+	}
+	assert.Equal(t, New(input), New(asMap), "round trip through AsMap should be stable")
+}
+
 func TestGetEmpty(t *testing.T) {
 	bm := ByteMap(nil)
 	assert.Nil(t, bm.Get("unspecified"))
@@ -84,6 +417,86 @@ func TestAsMap(t *testing.T) {
 	}
 }
 
+func TestAsMapWithPresence(t *testing.T) {
+	bm := New(map[string]interface{}{"a": 1, "b": nil, "c": "c", "d": nil})
+	values, present := bm.AsMapWithPresence()
+	assert.Equal(t, bm.AsMap(), values)
+	assert.Equal(t, map[string]bool{"a": true, "b": true, "c": true, "d": true}, present)
+	assert.Nil(t, values["b"])
+	assert.True(t, present["b"])
+	assert.Nil(t, values["e"])
+	assert.False(t, present["e"])
+
+	values, present = ByteMap(nil).AsMapWithPresence()
+	assert.Empty(t, values)
+	assert.Empty(t, present)
+}
+
+func TestKeysAndValues(t *testing.T) {
+	bm := New(m)
+	keys := bm.Keys()
+	values := bm.Values()
+	assert.Equal(t, len(m), bm.Len())
+	assert.Len(t, keys, len(m))
+	assert.Len(t, values, len(m))
+	assert.True(t, sort.StringsAreSorted(keys))
+	for i, key := range keys {
+		assert.Equal(t, m[key], values[i], key)
+	}
+
+	empty := ByteMap(nil)
+	assert.Zero(t, empty.Len())
+	assert.Empty(t, empty.Keys())
+	assert.Empty(t, empty.Values())
+}
+
+func TestEntries(t *testing.T) {
+	bm := New(m)
+	keys := bm.Keys()
+	values := bm.Values()
+	entries := bm.Entries()
+	if assert.Len(t, entries, len(m)) {
+		for i, e := range entries {
+			assert.Equal(t, keys[i], e.Key)
+			assert.Equal(t, values[i], e.Value, e.Key)
+		}
+	}
+	assert.Equal(t, bm, FromSortedKVs(entries))
+
+	ordered := BuildOrdered([]KV{{"b", 1}, {"a", nil}})
+	assert.Equal(t, []KV{{"b", 1}, {"a", nil}}, ordered.Entries())
+
+	assert.Empty(t, ByteMap(nil).Entries())
+	assert.NotNil(t, ByteMap(nil).Entries())
+}
+
+func TestKeyAtAndEntryAt(t *testing.T) {
+	bm := New(m)
+	keys := bm.Keys()
+	for _, i := range []int{0, len(keys) / 2, len(keys) - 1} {
+		key, ok := bm.KeyAt(i)
+		assert.True(t, ok)
+		assert.Equal(t, keys[i], key)
+		key, value, ok := bm.EntryAt(i)
+		assert.True(t, ok)
+		assert.Equal(t, keys[i], key)
+		assert.Equal(t, m[key], value)
+	}
+	key, ok := bm.KeyAt(0)
+	assert.Equal(t, "bool", key)
+	key, ok = bm.KeyAt(len(keys) - 1)
+	assert.Equal(t, "uint64", key)
+
+	for _, i := range []int{-1, len(keys), len(keys) + 10} {
+		_, ok = bm.KeyAt(i)
+		assert.False(t, ok, "%d", i)
+		_, _, ok = bm.EntryAt(i)
+		assert.False(t, ok, "%d", i)
+	}
+	_, ok = ByteMap(nil).KeyAt(0)
+	assert.False(t, ok)
+}
+
 func TestIterateValueBytes(t *testing.T) {
 	mc := make(map[string]interface{}, len(m))
 	for key, value := range m {
@@ -104,6 +517,30 @@ func TestIterateValueBytes(t *testing.T) {
 	assert.Empty(t, mc)
 }
 
+func TestIterateOffsets(t *testing.T) {
+	bm := New(m)
+	seen := 0
+	bm.IterateOffsets(func(key string, typ byte, valueOffset int, valueLen int) bool {
+		seen++
+		expectedType, _, _ := bm.GetRaw(key)
+		assert.Equal(t, expectedType, typ, key)
+		if typ == TypeNil {
+			assert.Zero(t, valueOffset, key)
+			assert.Zero(t, valueLen, key)
+		} else {
+			assert.Equal(t, bm.GetBytes(key), []byte(bm[valueOffset:valueOffset+valueLen]), key)
+		}
+		return true
+	})
+	assert.Equal(t, len(m), seen)
+
+	ordered := BuildOrdered([]KV{{"b", "b"}, {"a", 1}})
+	ordered.IterateOffsets(func(key string, typ byte, valueOffset int, valueLen int) bool {
+		assert.Equal(t, ordered.GetBytes(key), []byte(ordered[valueOffset:valueOffset+valueLen]), key)
+		return true
+	})
+}
+
 func TestAsMapEmpty(t *testing.T) {
 	bm := ByteMap(nil)
 	assert.Empty(t, bm.AsMap())
@@ -143,6 +580,127 @@ func TestFromSortedKeysAndFloats(t *testing.T) {
 	assert.EqualValues(t, bm1, bm2)
 }
 
+func TestNewBytes(t *testing.T) {
+	bm := NewBytes(map[string][]byte{
+		"a":     {1, 2, 3},
+		"empty": {},
+		"nil":   nil,
+	})
+	assert.Equal(t, []byte{1, 2, 3}, bm.Get("a"))
+	empty, ok := bm.Get("empty").([]byte)
+	assert.True(t, ok)
+	assert.NotNil(t, empty)
+	assert.Empty(t, empty)
+	assert.Nil(t, bm.Get("nil"))
+	typ, _, ok := bm.GetRaw("nil")
+	assert.True(t, ok, "nil key should be present")
+	assert.EqualValues(t, TypeNil, typ)
+
+	assert.Equal(t, New(map[string]interface{}{"a": []byte{1}, "b": nil}), NewBytes(map[string][]byte{"a": {1}, "b": nil}))
+}
+
+func TestEstimateSize(t *testing.T) {
+	assert.Equal(t, len(New(m)), EstimateSize(m))
+	assert.Equal(t, 0, EstimateSize(nil))
+
+	// A first key that looks like a header makes New add a real one
+	headerLike := map[string]interface{}{strings.Repeat("a", math.MaxUint16): 1, "b": "b"}
+	assert.Equal(t, len(New(headerLike)), EstimateSize(headerLike))
+}
+
+func TestMemoryFootprint(t *testing.T) {
+	bm := New(m)
+	assert.Equal(t, len(bm)+24, bm.MemoryFootprint())
+	assert.Equal(t, 24, ByteMap(nil).MemoryFootprint())
+
+	sliced := bm.Slice(sliceKeys)
+	assert.Equal(t, cap(sliced)+24, sliced.MemoryFootprint())
+	assert.Equal(t, len(sliced)+24, sliced.MemoryFootprint(), "Slice should copy into a right-sized array")
+
+	buf := make([]byte, 0, 1000)
+	view := ByteMap(append(buf, bm...))
+	assert.Equal(t, 1000+24, view.MemoryFootprint(), "view should report its backing array's cap")
+
+	inner := New(map[string]interface{}{"a": 1})
+	nested := New(map[string]interface{}{"inner": inner, "z": "zzzzzzzz"}).Get("inner").(ByteMap)
+	assert.True(t, nested.MemoryFootprint() > len(nested)+24, "nested map should pin its parent")
+	assert.Equal(t, len(nested)+24, nested.Compact().MemoryFootprint())
+}
+
+func TestCompact(t *testing.T) {
+	assertCompact := func(bm ByteMap, msg string) {
+		assert.Equal(t, len(bm), cap(bm), msg)
+	}
+	assertCompact(New(m), "New")
+	assertCompact(FromSortedKeysAndFloats([]string{"a", "b"}, []float64{1, 2}), "FromSortedKeysAndFloats")
+	assertCompact(BuildOrdered([]KV{{"b", 1}, {"a", 2}}), "BuildOrdered")
+	assertCompact(NewArray([]interface{}{1, "b"}), "NewArray")
+	assertCompact(New(m).Slice(sliceKeys), "Slice")
+	assertCompact(New(wideMap).Slice(contiguousWideKeys), "Slice contiguous")
+	included, omitted := New(m).Split(sliceKeys)
+	assertCompact(included, "Split included")
+	assertCompact(omitted, "Split omitted")
+
+	buf := make([]byte, 0, 1000)
+	bm := append(ByteMap(buf), New(m)...)
+	compacted := bm.Compact()
+	assertCompact(compacted, "Compact")
+	assert.Equal(t, bm, compacted)
+	assert.Equal(t, m["string"], compacted.Get("string"))
+}
+
+func TestKeyOverhead(t *testing.T) {
+	assert.Equal(t, 7, KeyOverhead("", true))
+	assert.Equal(t, 3, KeyOverhead("", false))
+	for _, key := range []string{"a", "string", "nil"} {
+		value := m[key]
+		if key == "a" {
+			value = 1
+		}
+		bm := New(map[string]interface{}{key: value})
+		assert.Equal(t, len(bm), KeyOverhead(key, value != nil)+len(bm.GetBytes(key)), key)
+	}
+}
+
+func TestEmptyMap(t *testing.T) {
+	bm := New(map[string]interface{}{})
+	assert.Len(t, bm, 0)
+	assert.Equal(t, bm, New(nil))
+	assert.Nil(t, bm.Get("a"))
+	assert.Nil(t, bm.GetBytes("a"))
+	assert.Nil(t, bm.Get(""))
+	assert.Zero(t, bm.Len())
+	assert.Empty(t, bm.AsMap())
+	assert.Empty(t, bm.Keys())
+	iterated := false
+	bm.Iterate(true, true, func(key string, value interface{}, valueBytes []byte) bool {
+		iterated = true
+		return true
+	})
+	assert.False(t, iterated)
+	assert.Empty(t, bm.Slice(map[string]bool{"a": true}))
+}
+
+func TestSingleKeyMap(t *testing.T) {
+	// 2 byte key length + 1 byte key + 1 byte type + 4 byte offset + 8 byte int
+	bm := New(map[string]interface{}{"a": 5})
+	assert.Len(t, bm, 16)
+	assert.Equal(t, 5, bm.Get("a"))
+	assert.Equal(t, 1, bm.Len())
+	assert.Equal(t, map[string]interface{}{"a": 5}, bm.AsMap())
+
+	// 2 byte key length + 1 byte key + 1 byte type
+	bm = New(map[string]interface{}{"a": nil})
+	assert.Len(t, bm, 4)
+	assert.Equal(t, 1, bm.Len())
+	assert.Equal(t, map[string]interface{}{"a": nil}, bm.AsMap())
+
+	// 2 byte key length + 1 byte type + 4 byte offset + 2 byte length + 1 byte string
+	bm = New(map[string]interface{}{"": "x"})
+	assert.Len(t, bm, 10)
+	assert.Equal(t, "x", bm.Get(""))
+}
+
 func TestNilOnly(t *testing.T) {
 	m2 := map[string]interface{}{
 		"nil": nil,
@@ -152,6 +710,73 @@ func TestNilOnly(t *testing.T) {
 	assert.Nil(t, bm.Get("unspecified"))
 }
 
+func TestRebuild(t *testing.T) {
+	bm := New(m)
+	small := map[string]interface{}{"a": 1, "b": "b", "c": nil}
+	rebuilt := bm.Rebuild(small)
+	assert.Equal(t, New(small), rebuilt)
+	assert.True(t, &bm[0] == &rebuilt[0], "smaller map should reuse the backing array")
+
+	smallBM := New(small)
+	grown := smallBM.Rebuild(m)
+	assert.Equal(t, New(m), grown)
+	assert.False(t, &smallBM[0] == &grown[0], "larger map should get a new backing array")
+	assert.Equal(t, New(small), smallBM, "original should be untouched when growing")
+
+	assert.Equal(t, New(small), ByteMap(nil).Rebuild(small))
+}
+
+func TestTrySetInPlace(t *testing.T) {
+	bm := New(map[string]interface{}{"count": 1, "name": "abc", "nil": nil, "small": int16(1)})
+	assert.True(t, bm.TrySetInPlace("count", 2))
+	assert.Equal(t, 2, bm.Get("count"))
+	assert.True(t, bm.TrySetInPlace("count", 2.5), "same width, different type")
+	assert.Equal(t, 2.5, bm.Get("count"))
+	assert.True(t, bm.TrySetInPlace("name", "xyz"))
+	assert.Equal(t, "xyz", bm.Get("name"))
+
+	orig := append(ByteMap(nil), bm...)
+	assert.False(t, bm.TrySetInPlace("count", int32(3)), "different width")
+	assert.False(t, bm.TrySetInPlace("name", "longer"), "different width")
+	assert.False(t, bm.TrySetInPlace("small", 3), "different width")
+	assert.False(t, bm.TrySetInPlace("nil", 1), "nil has no value to overwrite")
+	assert.False(t, bm.TrySetInPlace("count", nil))
+	assert.False(t, bm.TrySetInPlace("missing", 1))
+	assert.Equal(t, orig, bm, "failed attempts should not mutate")
+	assert.NoError(t, bm.Validate())
+
+	narrow, err := NewWithOptions(map[string]interface{}{"name": "abc"}, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		assert.False(t, narrow.TrySetInPlace("name", "x"), "non-default formats aren't supported")
+	}
+	ordered := BuildOrdered([]KV{{"b", 1}, {"a", 2}})
+	assert.True(t, ordered.TrySetInPlace("a", 3))
+	assert.Equal(t, 3, ordered.Get("a"))
+
+	deduped := New(map[string]interface{}{"a": 1, "b": 1, "c": 2}).Deduplicate()
+	assert.False(t, deduped.TrySetInPlace("a", 5), "shared values must not be overwritten")
+	assert.Equal(t, 1, deduped.Get("b"))
+	assert.True(t, deduped.TrySetInPlace("c", 5), "unshared values can be overwritten")
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": 1, "c": 5}, deduped.AsMap())
+}
+
+func TestRebuildConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var bm ByteMap
+			for j := 0; j < 100; j++ {
+				input := map[string]interface{}{"i": i, "j": j, "s": strings.Repeat("x", j%10)}
+				bm = bm.Rebuild(input)
+				assert.Equal(t, input, bm.AsMap())
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
 func TestSlice(t *testing.T) {
 	bm := New(m)
 	bm2 := bm.Slice(sliceKeys)
@@ -165,6 +790,34 @@ func TestSlice(t *testing.T) {
 	}
 }
 
+func TestSliceContiguous(t *testing.T) {
+	bm := New(wideMap)
+	for _, keys := range [][]string{
+		{"key010", "key011", "key012", "key013"},
+		{"key000", "key001"},
+		{"key099"},
+		{"key010", "key011", "key013", "key014"},
+		{"key010", "key011", "key012", "unknown"},
+	} {
+		includeKeys := make(map[string]bool, len(keys))
+		expected := make(map[string]interface{}, len(keys))
+		for _, key := range keys {
+			includeKeys[key] = true
+			if value, found := wideMap[key]; found {
+				expected[key] = value
+			}
+		}
+		sliced := bm.Slice(includeKeys)
+		assert.Equal(t, expected, sliced.AsMap(), "%v", keys)
+		assert.Equal(t, New(expected), sliced, "%v", keys)
+	}
+}
+
+func TestSliceLeadingNil(t *testing.T) {
+	bm := New(map[string]interface{}{"a": nil, "b": 1, "c": 2})
+	assert.Equal(t, map[string]interface{}{"b": 1, "c": 2}, bm.Slice(map[string]bool{"b": true, "c": true}).AsMap())
+}
+
 func TestSliceEmpty(t *testing.T) {
 	bm := ByteMap(nil)
 	assert.Empty(t, bm.Slice(map[string]bool{"unspecified": true}).AsMap())
@@ -195,6 +848,104 @@ func TestSplit(t *testing.T) {
 		return true
 	})
 }
+func TestFromSortedKeysAndInts(t *testing.T) {
+	keys := []string{"a", "b", "c"}
+	values := []int64{math.MinInt64, 0, math.MaxInt64}
+	bm1 := New(map[string]interface{}{"a": values[0], "b": values[1], "c": values[2]})
+	bm2 := FromSortedKeysAndInts(keys, values)
+	assert.EqualValues(t, bm1, bm2)
+	assert.Empty(t, FromSortedKeysAndInts(nil, nil).AsMap())
+}
+
+func TestFromKVs(t *testing.T) {
+	var kvs []KV
+	for key, value := range m {
+		kvs = append(kvs, KV{key, value})
+	}
+	expected := New(m)
+	assert.EqualValues(t, expected, FromKVs(kvs))
+
+	sort.Slice(kvs, func(i, j int) bool {
+		return kvs[i].Key < kvs[j].Key
+	})
+	assert.EqualValues(t, expected, FromSortedKVs(kvs))
+
+	dupes := []KV{{"b", 1}, {"a", 2}, {"b", 3}}
+	assert.EqualValues(t, New(map[string]interface{}{"a": 2, "b": 3}), FromKVs(dupes))
+	assert.Equal(t, "b", dupes[0].Key, "input should not be reordered")
+	assert.Empty(t, FromKVs(nil).AsMap())
+}
+
+func TestBuildOrdered(t *testing.T) {
+	pairs := []KV{
+		{"name", "Bob"},
+		{"age", 42},
+		{"zip", nil},
+		{"city", "Austin"},
+		{"active", true},
+	}
+	bm := BuildOrdered(pairs)
+	assert.True(t, bm.IsOrdered())
+	assert.False(t, New(m).IsOrdered())
+
+	var keys []string
+	bm.IterateValues(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	if assert.Len(t, keys, len(pairs)) {
+		for i, pair := range pairs {
+			assert.Equal(t, pair.Key, keys[i])
+			assert.Equal(t, pair.Value, bm.Get(pair.Key))
+		}
+	}
+	assert.Nil(t, bm.Get("unspecified"))
+
+	sliced := bm.Slice(map[string]bool{"city": true, "name": true})
+	assert.True(t, sliced.IsOrdered())
+	assert.Equal(t, map[string]interface{}{"name": "Bob", "city": "Austin"}, sliced.AsMap())
+}
+
+func TestConcurrentReads(t *testing.T) {
+	bm := New(m)
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for key, value := range m {
+					assert.Equal(t, value, bm.Get(key))
+				}
+				bm.Iterate(true, true, func(key string, value interface{}, valueBytes []byte) bool {
+					return true
+				})
+				assert.Len(t, bm.AsMap(), len(m))
+				bm.Slice(sliceKeys)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestSliceExcept(t *testing.T) {
+	bm := New(m)
+	_, expected := bm.Split(sliceKeys)
+	var keys []string
+	for key := range sliceKeys {
+		keys = append(keys, key)
+	}
+	actual := bm.SliceExcept(keys...)
+	assert.Equal(t, expected, actual)
+	for key, value := range m {
+		if sliceKeys[key] {
+			assert.Nil(t, actual.Get(key))
+		} else {
+			assert.Equal(t, value, actual.Get(key))
+		}
+	}
+	assert.Equal(t, New(wideMap), New(wideMap).SliceExcept())
+}
 
 func BenchmarkNew(b *testing.B) {
 	for i := 0; i < b.N; i++ {
@@ -202,6 +953,33 @@ func BenchmarkNew(b *testing.B) {
 	}
 }
 
+var bytesMap = map[string][]byte{
+	"a": []byte("alpha"),
+	"b": []byte("bravo"),
+	"c": []byte("charlie"),
+	"d": []byte("delta"),
+	"e": []byte("echo"),
+}
+
+func BenchmarkNewBytes(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewBytes(bytesMap)
+	}
+}
+
+func BenchmarkNewBytesBoxed(b *testing.B) {
+	boxed := make(map[string]interface{}, len(bytesMap))
+	for key, value := range bytesMap {
+		boxed[key] = value
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New(boxed)
+	}
+}
+
 func BenchmarkFromSortedKeysAndValues(b *testing.B) {
 	var keys []string
 	var values []interface{}
@@ -219,6 +997,38 @@ func BenchmarkFromSortedKeysAndValues(b *testing.B) {
 	}
 }
 
+func BenchmarkFromSortedKeysAndInts(b *testing.B) {
+	keys, values := sortedIntColumn(1000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FromSortedKeysAndInts(keys, values)
+	}
+}
+
+func BenchmarkNewInts(b *testing.B) {
+	keys, values := sortedIntColumn(1000)
+	boxed := make(map[string]interface{}, len(keys))
+	for i, key := range keys {
+		boxed[key] = values[i]
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New(boxed)
+	}
+}
+
+func sortedIntColumn(n int) ([]string, []int64) {
+	keys := make([]string, n)
+	values := make([]int64, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%04d", i)
+		values[i] = int64(i) * 1000
+	}
+	return keys, values
+}
+
 func BenchmarkByteMapAllKeys(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		bm := New(m)
@@ -243,6 +1053,49 @@ func BenchmarkByteSlice(b *testing.B) {
 	}
 }
 
+var (
+	wideMap            = make(map[string]interface{}, 100)
+	contiguousWideKeys = make(map[string]bool, 10)
+)
+
+func init() {
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%03d", i)
+		if i%2 == 0 {
+			wideMap[key] = i
+		} else {
+			wideMap[key] = key
+		}
+		if i >= 40 && i < 50 {
+			contiguousWideKeys[key] = true
+		}
+	}
+}
+
+func BenchmarkByteSliceContiguous(b *testing.B) {
+	bm := New(wideMap)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Slice(contiguousWideKeys)
+	}
+}
+
+func BenchmarkSliceExcept(b *testing.B) {
+	bm := New(wideMap)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.SliceExcept("key010", "key050")
+	}
+}
+
+func BenchmarkSplitDiscardingIncluded(b *testing.B) {
+	bm := New(wideMap)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = bm.Split(map[string]bool{"key010": true, "key050": true})
+	}
+}
+
 func BenchmarkMsgPackAllKeys(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		b, _ := msgpack.Marshal(m)
@@ -284,6 +1137,33 @@ func BenchmarkMsgPackSlice(b *testing.B) {
 
 var testKeys = []string{"uint64", "float32", "int16"}
 
+func BenchmarkHas(b *testing.B) {
+	bm := New(m)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Has("string")
+	}
+}
+
+func BenchmarkHasMissBeforeAll(b *testing.B) {
+	bm := New(m)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Has("a")
+	}
+}
+
+func BenchmarkHasMissAfterAll(b *testing.B) {
+	bm := New(m)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Has("zzz")
+	}
+}
+
 func BenchmarkReadKeysIndividually(b *testing.B) {
 	bm := New(m)
 	b.ResetTimer()
@@ -310,3 +1190,59 @@ func BenchmarkReadKeysIteration(b *testing.B) {
 		})
 	}
 }
+
+func BenchmarkGetBool(b *testing.B) {
+	benchmarkGet(b, "bool")
+}
+
+func BenchmarkGetInt(b *testing.B) {
+	benchmarkGet(b, "int")
+}
+
+func BenchmarkGetFloat64(b *testing.B) {
+	benchmarkGet(b, "float64")
+}
+
+func BenchmarkGetString(b *testing.B) {
+	benchmarkGet(b, "string")
+}
+
+func BenchmarkGetBytes(b *testing.B) {
+	benchmarkGet(b, "bytes")
+}
+
+func BenchmarkGetTime(b *testing.B) {
+	benchmarkGet(b, "time")
+}
+
+func BenchmarkGetFloat64s(b *testing.B) {
+	benchmarkGet(b, "float64s")
+}
+
+func benchmarkGet(b *testing.B, key string) {
+	bm := New(m)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Get(key)
+	}
+}
+
+// BenchmarkDecodeValue measures decoding each type in isolation, without
+// looking up the key.
+func BenchmarkDecodeValue(b *testing.B) {
+	bm := New(m)
+	keys := bm.Keys()
+	for _, key := range keys {
+		t, valueOffset, _ := bm.find(key)
+		if t == TypeNil {
+			continue
+		}
+		b.Run(key, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				bm.decodeValueAt(valueOffset, t)
+			}
+		})
+	}
+}
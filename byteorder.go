@@ -0,0 +1,63 @@
+package bytemap
+
+import (
+	"encoding/binary"
+)
+
+// littleEndianValue returns a copy of the given big-endian encoded value bytes
+// of type t with all multi-byte integers converted to little-endian. It
+// doesn't handle strings, whose length prefixes may have other widths, or
+// delta encoded times (see defaultEncoding).
+func littleEndianValue(t byte, value []byte) []byte {
+	b := make([]byte, len(value))
+	copy(b, value)
+	switch t {
+	case TypeUInt16, TypeInt16, TypeUInt32, TypeInt32, TypeFloat32,
+		TypeUInt64, TypeUInt, TypeInt64, TypeInt, TypeFloat64, TypeTime:
+		reverse(b)
+	case TypeInts, TypeFloat64s:
+		swap16(b)
+		for i := 2; i+8 <= len(b); i += 8 {
+			reverse(b[i : i+8])
+		}
+	case TypeStrings:
+		swap16(b)
+		for i := 2; i+2 <= len(b); {
+			l := int(binary.BigEndian.Uint16(b[i:]))
+			swap16(b[i:])
+			i += 2 + l
+		}
+	case TypeByteMap, TypeProto, TypeJSON, TypeBigInt, TypeBigFloat:
+		swap32(b)
+	case TypeCompressed:
+		swap32(b)
+		if len(b) >= 4+compressedHeaderLen {
+			swap32(b[5:])
+		}
+	case TypeBytes, TypeInt8s:
+		swap16(b)
+	default:
+		if t >= TypeUserMin {
+			swap16(b)
+		}
+	}
+	return b
+}
+
+func swap16(b []byte) {
+	if len(b) >= 2 {
+		reverse(b[:2])
+	}
+}
+
+func swap32(b []byte) {
+	if len(b) >= 4 {
+		reverse(b[:4])
+	}
+}
+
+func reverse(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
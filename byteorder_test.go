@@ -0,0 +1,99 @@
+package bytemap
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestByteOrder(t *testing.T) {
+	assert.Equal(t, binary.LittleEndian, New(m).ByteOrder())
+	assert.Equal(t, binary.LittleEndian, ByteMap(nil).ByteOrder())
+	assert.Equal(t, binary.LittleEndian, BuildOrdered([]KV{{"a", 1}}).ByteOrder())
+	assert.Equal(t, binary.BigEndian, bigEndianMap().ByteOrder())
+}
+
+func TestReadBigEndian(t *testing.T) {
+	bm := bigEndianMap()
+	assert.NoError(t, bm.Validate())
+	expected := map[string]interface{}{
+		"a": int32(-2),
+		"b": "hi",
+		"c": []float64{1.5, -3},
+		"d": time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC),
+		"e": uint16(0x0102),
+		"f": New(map[string]interface{}{"x": 1}),
+		"g": []string{"x", "yz"},
+		"h": nil,
+	}
+	for key, value := range expected {
+		assert.Equal(t, value, bm.Get(key), key)
+	}
+	assert.Equal(t, expected, bm.AsMap())
+	assert.Equal(t, uint16(0x0102), GetOr(bm, "e", uint16(0)))
+
+	bm.IterateTyped(func(key string, v Value) bool {
+		if key == "a" {
+			i, ok := v.Int()
+			assert.True(t, ok)
+			assert.EqualValues(t, -2, i)
+		}
+		return true
+	})
+
+	// Values are converted when copied into little-endian maps
+	le := New(expected)
+	assert.True(t, bm.Equal(le))
+	assert.Equal(t, le, bm.Merge(nil))
+
+	// Slicing keeps the byte order
+	sliced := bm.Slice(map[string]bool{"a": true, "g": true})
+	assert.Equal(t, binary.BigEndian, sliced.ByteOrder())
+	assert.Equal(t, map[string]interface{}{"a": int32(-2), "g": []string{"x", "yz"}}, sliced.AsMap())
+	contiguous := bm.Slice(map[string]bool{"a": true, "b": true})
+	assert.Equal(t, map[string]interface{}{"a": int32(-2), "b": "hi"}, contiguous.AsMap())
+	included, omitted := bm.Split(map[string]bool{"b": true, "e": true})
+	assert.Equal(t, map[string]interface{}{"b": "hi", "e": uint16(0x0102)}, included.AsMap())
+	assert.Equal(t, int32(-2), omitted.Get("a"))
+}
+
+// bigEndianMap builds a big-endian map by hand, the way a big-endian producer
+// would.
+func bigEndianMap() ByteMap {
+	be := binary.BigEndian
+	nested := New(map[string]interface{}{"x": 1})
+	entries := []struct {
+		key   string
+		t     byte
+		value []byte
+	}{
+		{"a", TypeInt32, be.AppendUint32(nil, uint32(0xFFFFFFFE))},
+		{"b", TypeString, append(be.AppendUint16(nil, 2), "hi"...)},
+		{"c", TypeFloat64s, be.AppendUint64(be.AppendUint64(be.AppendUint16(nil, 2), math.Float64bits(1.5)), math.Float64bits(-3))},
+		{"d", TypeTime, be.AppendUint64(nil, uint64(time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC).UnixNano()))},
+		{"e", TypeUInt16, []byte{1, 2}},
+		{"f", TypeByteMap, append(be.AppendUint32(nil, uint32(len(nested))), nested...)},
+		{"g", TypeStrings, append(be.AppendUint16(nil, 7), 0, 1, 'x', 0, 2, 'y', 'z')},
+		{"h", TypeNil, nil},
+	}
+
+	bm := ByteMap(newHeader(FlagBigEndian))
+	valueOffset := len(bm)
+	for _, e := range entries {
+		valueOffset += KeyOverhead(e.key, e.t != TypeNil)
+	}
+	var values []byte
+	for _, e := range entries {
+		bm = be.AppendUint16(bm, uint16(len(e.key)))
+		bm = append(bm, e.key...)
+		bm = append(bm, e.t)
+		if e.t != TypeNil {
+			bm = be.AppendUint32(bm, uint32(valueOffset+len(values)))
+			values = append(values, e.value...)
+		}
+	}
+	return append(bm, values...)
+}
@@ -0,0 +1,466 @@
+package bytemap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// ByteMaps can be converted to and from CBOR (RFC 8949). Values are mapped to
+// CBOR as follows:
+//
+//	nil                         -> null
+//	bool                        -> true/false
+//	unsigned integers           -> unsigned integer (major type 0)
+//	signed integers             -> unsigned or negative integer (major type 0/1)
+//	float32, float64            -> single or double precision float
+//	string                      -> text string (major type 3)
+//	[]byte                      -> byte string (major type 2)
+//	time.Time                   -> tag 1 (epoch time) around the number of
+//	                               seconds, which is a float if the time has a
+//	                               fractional second
+//	[]int, []float64, []int8,
+//	[]string, arrays and JSON
+//	arrays                      -> array (major type 4)
+//	nested ByteMaps and JSON
+//	objects                     -> map (major type 5)
+//	*big.Int                    -> integer if it fits in an int64, otherwise
+//	                               tag 2/3 (bignum)
+//
+// Other values (*big.Float and registered user types) can't be converted. When
+// decoding, integers become int64 (or uint64 if they're too large, or *big.Int
+// if they're too small), floats keep their precision (half precision floats
+// become float64), arrays become nested arrays (see NewArray) and maps become
+// nested ByteMaps. Epoch times (tag 1) and RFC 3339 times (tag 0) become UTC
+// times. Other tags are ignored, and indefinite length items as well as text
+// and byte strings longer than math.MaxUint16 bytes aren't supported.
+//
+// Because the fractional seconds of times are stored as floats, times don't
+// round trip with nanosecond precision.
+
+const (
+	cborUint = iota
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+const (
+	cborFalse   = 20
+	cborTrue    = 21
+	cborNull    = 22
+	cborUndef   = 23
+	cborFloat16 = 25
+	cborFloat32 = 26
+	cborFloat64 = 27
+
+	cborTagTime      = 0
+	cborTagEpochTime = 1
+	cborTagBignum    = 2
+	cborTagNegBignum = 3
+
+	// maxCBORDepth limits the nesting of decoded arrays and maps
+	maxCBORDepth = 100
+)
+
+var cborEnc = binary.BigEndian
+
+// ToCBOR encodes this ByteMap as a CBOR map.
+func (bm ByteMap) ToCBOR() ([]byte, error) {
+	return appendCBORMap(nil, bm)
+}
+
+// FromCBOR builds a ByteMap from the given CBOR map.
+func FromCBOR(b []byte) (ByteMap, error) {
+	if len(b) == 0 || b[0]>>5 != cborMap {
+		return nil, fmt.Errorf("bytemap: CBOR data isn't a map")
+	}
+	d := &cborDecoder{b: b}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.b) > 0 {
+		return nil, fmt.Errorf("bytemap: unexpected data after CBOR map")
+	}
+	return value.(ByteMap), nil
+}
+
+func appendCBORMap(b []byte, bm ByteMap) ([]byte, error) {
+	if bm.IsArray() {
+		return appendCBORArray(b, bm.AsSlice())
+	}
+	var entries []KV
+	bm.IterateValues(func(key string, value interface{}) bool {
+		entries = append(entries, KV{key, value})
+		return true
+	})
+	return appendCBORKVs(b, entries)
+}
+
+// appendCBORObject appends a decoded JSON object (e.g. from a TypeJSON value)
+// as a map, with its keys in sorted order.
+func appendCBORObject(b []byte, m map[string]interface{}) ([]byte, error) {
+	entries := make([]KV, 0, len(m))
+	for key, value := range m {
+		entries = append(entries, KV{key, value})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return appendCBORKVs(b, entries)
+}
+
+func appendCBORKVs(b []byte, entries []KV) ([]byte, error) {
+	b = appendCBORHead(b, cborMap, uint64(len(entries)))
+	for _, e := range entries {
+		b = appendCBORHead(b, cborText, uint64(len(e.Key)))
+		b = append(b, e.Key...)
+		var err error
+		b, err = appendCBORValue(b, e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("bytemap: unable to encode value of %v: %v", e.Key, err)
+		}
+	}
+	return b, nil
+}
+
+func appendCBORArray(b []byte, values []interface{}) ([]byte, error) {
+	b = appendCBORHead(b, cborArray, uint64(len(values)))
+	for _, value := range values {
+		var err error
+		b, err = appendCBORValue(b, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+func appendCBORValue(b []byte, value interface{}) ([]byte, error) {
+	switch v := value.(type) {
+	case nil:
+		return append(b, cborSimple<<5|cborNull), nil
+	case bool:
+		if v {
+			return append(b, cborSimple<<5|cborTrue), nil
+		}
+		return append(b, cborSimple<<5|cborFalse), nil
+	case byte:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case uint16:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case uint32:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case uint64:
+		return appendCBORHead(b, cborUint, v), nil
+	case uint:
+		return appendCBORHead(b, cborUint, uint64(v)), nil
+	case int8:
+		return appendCBORInt(b, int64(v)), nil
+	case int16:
+		return appendCBORInt(b, int64(v)), nil
+	case int32:
+		return appendCBORInt(b, int64(v)), nil
+	case int64:
+		return appendCBORInt(b, v), nil
+	case int:
+		return appendCBORInt(b, int64(v)), nil
+	case float32:
+		b = append(b, cborSimple<<5|cborFloat32, 0, 0, 0, 0)
+		cborEnc.PutUint32(b[len(b)-4:], math.Float32bits(v))
+		return b, nil
+	case float64:
+		return appendCBORFloat64(b, v), nil
+	case string:
+		b = appendCBORHead(b, cborText, uint64(len(v)))
+		return append(b, v...), nil
+	case []byte:
+		b = appendCBORHead(b, cborBytes, uint64(len(v)))
+		return append(b, v...), nil
+	case time.Time:
+		b = appendCBORHead(b, cborTag, cborTagEpochTime)
+		if v.Nanosecond() == 0 {
+			return appendCBORInt(b, v.Unix()), nil
+		}
+		return appendCBORFloat64(b, float64(v.UnixNano())/float64(time.Second)), nil
+	case []int:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		for _, i := range v {
+			b = appendCBORInt(b, int64(i))
+		}
+		return b, nil
+	case []float64:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		for _, f := range v {
+			b = appendCBORFloat64(b, f)
+		}
+		return b, nil
+	case []int8:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		for _, i := range v {
+			b = appendCBORInt(b, int64(i))
+		}
+		return b, nil
+	case []string:
+		b = appendCBORHead(b, cborArray, uint64(len(v)))
+		for _, s := range v {
+			b = appendCBORHead(b, cborText, uint64(len(s)))
+			b = append(b, s...)
+		}
+		return b, nil
+	case ByteMap:
+		return appendCBORMap(b, v)
+	case map[string]interface{}:
+		return appendCBORObject(b, v)
+	case []interface{}:
+		return appendCBORArray(b, v)
+	case *big.Int:
+		if v.IsInt64() {
+			return appendCBORInt(b, v.Int64()), nil
+		}
+		if v.Sign() >= 0 {
+			b = appendCBORHead(b, cborTag, cborTagBignum)
+			mag := v.Bytes()
+			b = appendCBORHead(b, cborBytes, uint64(len(mag)))
+			return append(b, mag...), nil
+		}
+		// Negative bignums store -1 - n
+		mag := new(big.Int).Sub(big.NewInt(-1), v).Bytes()
+		b = appendCBORHead(b, cborTag, cborTagNegBignum)
+		b = appendCBORHead(b, cborBytes, uint64(len(mag)))
+		return append(b, mag...), nil
+	}
+	return nil, fmt.Errorf("type %T can't be represented in CBOR", value)
+}
+
+func appendCBORInt(b []byte, i int64) []byte {
+	if i >= 0 {
+		return appendCBORHead(b, cborUint, uint64(i))
+	}
+	return appendCBORHead(b, cborNegInt, uint64(-1-i))
+}
+
+func appendCBORFloat64(b []byte, f float64) []byte {
+	b = append(b, cborSimple<<5|cborFloat64, 0, 0, 0, 0, 0, 0, 0, 0)
+	cborEnc.PutUint64(b[len(b)-8:], math.Float64bits(f))
+	return b
+}
+
+// appendCBORHead appends the initial byte (and any following argument bytes)
+// of an item of the given major type with the given argument.
+func appendCBORHead(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, major|24, byte(n))
+	case n <= math.MaxUint16:
+		b = append(b, major|25, 0, 0)
+		cborEnc.PutUint16(b[len(b)-2:], uint16(n))
+	case n <= math.MaxUint32:
+		b = append(b, major|26, 0, 0, 0, 0)
+		cborEnc.PutUint32(b[len(b)-4:], uint32(n))
+	default:
+		b = append(b, major|27, 0, 0, 0, 0, 0, 0, 0, 0)
+		cborEnc.PutUint64(b[len(b)-8:], n)
+	}
+	return b
+}
+
+type cborDecoder struct {
+	b []byte
+}
+
+// head reads the initial byte of an item and its argument. For simple values
+// and floats, n holds the raw bits.
+func (d *cborDecoder) head() (major byte, info byte, n uint64, err error) {
+	if len(d.b) == 0 {
+		return 0, 0, 0, fmt.Errorf("bytemap: truncated CBOR data")
+	}
+	major, info = d.b[0]>>5, d.b[0]&0x1f
+	d.b = d.b[1:]
+	width := 0
+	switch {
+	case info < 24:
+		return major, info, uint64(info), nil
+	case info == 24:
+		width = 1
+	case info == 25:
+		width = 2
+	case info == 26:
+		width = 4
+	case info == 27:
+		width = 8
+	default:
+		return 0, 0, 0, fmt.Errorf("bytemap: unsupported CBOR additional information %d", info)
+	}
+	if len(d.b) < width {
+		return 0, 0, 0, fmt.Errorf("bytemap: truncated CBOR data")
+	}
+	switch width {
+	case 1:
+		n = uint64(d.b[0])
+	case 2:
+		n = uint64(cborEnc.Uint16(d.b))
+	case 4:
+		n = uint64(cborEnc.Uint32(d.b))
+	case 8:
+		n = cborEnc.Uint64(d.b)
+	}
+	d.b = d.b[width:]
+	return major, info, n, nil
+}
+
+func (d *cborDecoder) decode(depth int) (interface{}, error) {
+	if depth > maxCBORDepth {
+		return nil, fmt.Errorf("bytemap: CBOR data nested too deeply")
+	}
+	major, info, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	switch major {
+	case cborUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case cborNegInt:
+		if n > math.MaxInt64 {
+			return new(big.Int).Sub(big.NewInt(-1), new(big.Int).SetUint64(n)), nil
+		}
+		return -1 - int64(n), nil
+	case cborBytes, cborText:
+		if n > uint64(len(d.b)) {
+			return nil, fmt.Errorf("bytemap: truncated CBOR data")
+		}
+		if n > math.MaxUint16 {
+			return nil, fmt.Errorf("bytemap: CBOR string is %d bytes long, which exceeds the maximum of %d bytes", n, math.MaxUint16)
+		}
+		data := d.b[:n]
+		d.b = d.b[n:]
+		if major == cborText {
+			return string(data), nil
+		}
+		return append([]byte{}, data...), nil
+	case cborArray:
+		// Every item takes at least one byte
+		if n > uint64(len(d.b)) {
+			return nil, fmt.Errorf("bytemap: truncated CBOR data")
+		}
+		values := make([]interface{}, n)
+		for i := range values {
+			if values[i], err = d.decode(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return NewArray(values), nil
+	case cborMap:
+		if n > uint64(len(d.b))/2 {
+			return nil, fmt.Errorf("bytemap: truncated CBOR data")
+		}
+		m := make(map[string]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			if len(d.b) == 0 || d.b[0]>>5 != cborText {
+				return nil, fmt.Errorf("bytemap: CBOR map keys must be text strings")
+			}
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if m[key.(string)], err = d.decode(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return newStrict(m)
+	case cborTag:
+		content, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return cborTagged(n, content)
+	}
+
+	switch info {
+	case cborFalse:
+		return false, nil
+	case cborTrue:
+		return true, nil
+	case cborNull, cborUndef:
+		return nil, nil
+	case cborFloat16:
+		return halfToFloat64(uint16(n)), nil
+	case cborFloat32:
+		return math.Float32frombits(uint32(n)), nil
+	case cborFloat64:
+		return math.Float64frombits(n), nil
+	}
+	return nil, fmt.Errorf("bytemap: unsupported CBOR simple value %d", n)
+}
+
+// cborTagged interprets the content of an item with the given tag.
+func cborTagged(tag uint64, content interface{}) (interface{}, error) {
+	switch tag {
+	case cborTagTime:
+		s, ok := content.(string)
+		if !ok {
+			return nil, fmt.Errorf("bytemap: CBOR time must be a string")
+		}
+		tm, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return nil, fmt.Errorf("bytemap: invalid CBOR time %v: %v", s, err)
+		}
+		return tm.UTC(), nil
+	case cborTagEpochTime:
+		switch v := content.(type) {
+		case int64:
+			return time.Unix(v, 0).UTC(), nil
+		case float64:
+			seconds := math.Floor(v)
+			return time.Unix(int64(seconds), int64(math.Round((v-seconds)*float64(time.Second)))).UTC(), nil
+		case float32:
+			return cborTagged(tag, float64(v))
+		}
+		return nil, fmt.Errorf("bytemap: CBOR epoch time must be a number")
+	case cborTagBignum, cborTagNegBignum:
+		b, ok := content.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("bytemap: CBOR bignum must be a byte string")
+		}
+		i := new(big.Int).SetBytes(b)
+		if tag == cborTagNegBignum {
+			i.Sub(big.NewInt(-1), i)
+		}
+		return i, nil
+	}
+	return content, nil
+}
+
+// halfToFloat64 converts an IEEE 754 half precision float to a float64.
+func halfToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1
+	}
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(mant, -24)
+	case 0x1f:
+		if mant == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(mant+1024, exp-25)
+}
@@ -0,0 +1,124 @@
+package bytemap
+
+import (
+	"encoding/hex"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCBORRoundTrip(t *testing.T) {
+	bm := New(m)
+	b, err := bm.ToCBOR()
+	if !assert.NoError(t, err) {
+		return
+	}
+	decoded, err := FromCBOR(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, bm.Keys(), decoded.Keys())
+
+	// Integers don't keep their width
+	for _, key := range []string{"byte", "uint16", "uint32", "int8", "int16", "int32", "int64", "int"} {
+		expected, _ := toFloat64(bm.Get(key))
+		actual, ok := decoded.Get(key).(int64)
+		if assert.True(t, ok, key) {
+			assert.Equal(t, expected, float64(actual), key)
+		}
+	}
+	assert.Equal(t, uint64(math.MaxUint64), decoded.Get("uint64"))
+	assert.Equal(t, uint64(math.MaxUint64), decoded.Get("uint"))
+	assert.Equal(t, []interface{}{int64(math.MaxInt64), int64(math.MinInt64)}, decoded.Get("ints").(ByteMap).AsSlice())
+	assert.Equal(t, []interface{}{int64(math.MinInt8), int64(-1), int64(0), int64(1), int64(math.MaxInt8)}, decoded.Get("int8s").(ByteMap).AsSlice())
+
+	// Everything else is preserved
+	for _, key := range []string{"bool", "float32", "float64", "string", "bytes", "nil"} {
+		assert.Equal(t, bm.Get(key), decoded.Get(key), key)
+	}
+	assert.Equal(t, []interface{}{math.MaxFloat64, -1 * math.MaxFloat64, float64(0)}, decoded.Get("float64s").(ByteMap).AsSlice())
+	assert.WithinDuration(t, bm.Get("time").(time.Time), decoded.Get("time").(time.Time), time.Microsecond)
+}
+
+func TestCBORNested(t *testing.T) {
+	bm := New(map[string]interface{}{
+		"map":     New(map[string]interface{}{"a": "b"}),
+		"array":   NewArray([]interface{}{1, "two"}),
+		"strings": []string{"a", "b"},
+		"big":     new(big.Int).Lsh(big.NewInt(1), 100),
+		"negbig":  new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 100)),
+		"time":    time.Unix(1500000000, 0),
+	})
+	b, err := bm.ToCBOR()
+	if !assert.NoError(t, err) {
+		return
+	}
+	decoded, err := FromCBOR(b)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "b", decoded.Get("map").(ByteMap).Get("a"))
+	assert.Equal(t, []interface{}{int64(1), "two"}, decoded.Get("array").(ByteMap).AsSlice())
+	assert.Equal(t, []interface{}{"a", "b"}, decoded.Get("strings").(ByteMap).AsSlice())
+	assert.Equal(t, 0, bm.Get("big").(*big.Int).Cmp(decoded.Get("big").(*big.Int)))
+	assert.Equal(t, 0, bm.Get("negbig").(*big.Int).Cmp(decoded.Get("negbig").(*big.Int)))
+	assert.Equal(t, bm.Get("time"), decoded.Get("time"))
+}
+
+func TestFromCBORExamples(t *testing.T) {
+	// Examples from RFC 8949, Appendix A
+	bm, err := FromCBOR(mustHex("a56161614161626142616361436164614461656145"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]interface{}{"a": "A", "b": "B", "c": "C", "d": "D", "e": "E"}, bm.AsMap())
+	}
+	bm, err = FromCBOR(mustHex("a461610f616239e7ff6163f9c400616474323031332d30332d32315432303a30343a30305a"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(15), bm.Get("a"))
+		assert.Equal(t, int64(-59392), bm.Get("b"))
+		assert.Equal(t, -4.0, bm.Get("c"))
+		assert.Equal(t, "2013-03-21T20:04:00Z", bm.Get("d"))
+	}
+	bm, err = FromCBOR(mustHex("a16174c074323031332d30332d32315432303a30343a30305a"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2013, 3, 21, 20, 4, 0, 0, time.UTC), bm.Get("t"))
+	}
+}
+
+func TestCBORErrors(t *testing.T) {
+	for _, data := range []string{"", "01", "80", "a1", "a16161", "a1016161", "a1616178", "a16161f8", "a0ff", "a0a0", "bf"} {
+		_, err := FromCBOR(mustHex(data))
+		assert.Error(t, err, data)
+	}
+	_, err := New(map[string]interface{}{"a": big.NewFloat(1)}).ToCBOR()
+	assert.Error(t, err)
+
+	longKey := strings.Repeat("k", MaxKeyLen+1)
+	data := appendCBORHead([]byte{0xa1}, 3, uint64(len(longKey)))
+	data = append(append(data, longKey...), 0x01)
+	_, err = FromCBOR(data)
+	assert.Error(t, err, "key too long")
+
+	for _, major := range []byte{cborText, cborBytes} {
+		longest := strings.Repeat("s", math.MaxUint16)
+		data = appendCBORHead(mustHex("a16161"), major, uint64(len(longest)))
+		bm, err := FromCBOR(append(data, longest...))
+		if assert.NoError(t, err) {
+			assert.EqualValues(t, len(longest), len(bm.GetBytes("a"))-2)
+		}
+		data = appendCBORHead(mustHex("a16161"), major, uint64(len(longest)+1))
+		_, err = FromCBOR(append(append(data, longest...), 's'))
+		assert.Error(t, err, "string too long")
+	}
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,127 @@
+package bytemap
+
+import (
+	"fmt"
+	"io"
+)
+
+// ChunkedBuilder builds ByteMaps by streaming them to an io.Writer, so that the
+// whole map never needs to be held in memory (for example when building huge
+// maps directly to a file).
+//
+// Because value offsets are stored in the key region, which precedes all of
+// the values, building makes two passes over the input:
+//
+//  1. The first pass encodes each value (into a scratch buffer that is reused)
+//     to learn its type and length and builds the key region in memory, with
+//     value offsets relative to the start of the value region. Once all keys
+//     are known, the length of the key region is added to each offset and the
+//     key region is written.
+//  2. The second pass encodes each value again and writes it.
+//
+// Peak memory use is therefore the size of the key region plus the size of
+// the largest value. The output is identical to what Build would produce.
+type ChunkedBuilder struct {
+	w       io.Writer
+	scratch []byte
+}
+
+// NewChunkedBuilder creates a new ChunkedBuilder that writes to w.
+func NewChunkedBuilder(w io.Writer) *ChunkedBuilder {
+	return &ChunkedBuilder{w: w}
+}
+
+// Build writes a ByteMap containing the key/value pairs yielded by iterate and
+// returns the number of bytes written. iterate is called twice and must yield
+// the same pairs, in lexicographically sorted key order, both times. Build
+// returns an error if it doesn't, if a key is longer than MaxKeyLen bytes or if
+// writing fails.
+func (b *ChunkedBuilder) Build(iterate func(func(string, interface{}))) (int64, error) {
+	var keys []byte
+	var headerLen int
+	var lens []int
+	var err error
+	prevKey := ""
+	valueOffset := 0
+	iterate(func(key string, value interface{}) {
+		if err != nil {
+			return
+		}
+		if len(lens) > 0 && key <= prevKey {
+			err = fmt.Errorf("bytemap: key %v isn't in sorted order", key)
+			return
+		}
+		if err = checkKeyLen(key, MaxKeyLen); err != nil {
+			return
+		}
+		if len(lens) == 0 && keyLooksLikeHeader(key) {
+			keys = newHeader(0)
+			headerLen = len(keys)
+		}
+		prevKey = key
+		t, n := b.encode(value)
+		keys = append(keys, 0, 0)
+		enc.PutUint16(keys[len(keys)-SizeKeyLen:], uint16(len(key)))
+		keys = append(keys, key...)
+		keys = append(keys, t)
+		if t != TypeNil {
+			keys = append(keys, 0, 0, 0, 0)
+			enc.PutUint32(keys[len(keys)-SizeValueOffset:], uint32(valueOffset))
+			valueOffset += n
+		}
+		lens = append(lens, n)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Rebase the value offsets onto the end of the key region
+	keysLen := len(keys)
+	for offset := headerLen; offset < keysLen; {
+		offset += SizeKeyLen + int(enc.Uint16(keys[offset:]))
+		t := keys[offset]
+		offset += SizeValueType
+		if t != TypeNil {
+			enc.PutUint32(keys[offset:], enc.Uint32(keys[offset:])+uint32(keysLen))
+			offset += SizeValueOffset
+		}
+	}
+
+	written, err := b.w.Write(keys)
+	total := int64(written)
+	if err != nil {
+		return total, err
+	}
+
+	i := 0
+	iterate(func(key string, value interface{}) {
+		if err != nil {
+			return
+		}
+		if i >= len(lens) {
+			err = fmt.Errorf("bytemap: iterate yielded more pairs the second time")
+			return
+		}
+		_, n := b.encode(value)
+		if n != lens[i] {
+			err = fmt.Errorf("bytemap: value of %v changed length between passes", key)
+			return
+		}
+		i++
+		written, err = b.w.Write(b.scratch[:n])
+		total += int64(written)
+	})
+	if err == nil && i < len(lens) {
+		err = fmt.Errorf("bytemap: iterate yielded fewer pairs the second time")
+	}
+	return total, err
+}
+
+// encode encodes the given value into the scratch buffer.
+func (b *ChunkedBuilder) encode(value interface{}) (byte, int) {
+	n := encodedLength(value)
+	if cap(b.scratch) < n {
+		b.scratch = make([]byte, n)
+	}
+	return encodeValue(b.scratch[:n], value)
+}
@@ -0,0 +1,88 @@
+package bytemap
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sortedIterate(m map[string]interface{}) func(func(string, interface{})) {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return func(cb func(string, interface{})) {
+		for _, key := range keys {
+			cb(key, m[key])
+		}
+	}
+}
+
+func TestChunkedBuilder(t *testing.T) {
+	for _, input := range []map[string]interface{}{
+		m,
+		{},
+		{"a": nil, "b": nil},
+		{"a": nil, "b": 1, "c": "c", "d": nil},
+		{strings.Repeat("k", MaxKeyLen): 1, "z": 2},
+	} {
+		var buf bytes.Buffer
+		b := NewChunkedBuilder(&buf)
+		n, err := b.Build(sortedIterate(input))
+		if !assert.NoError(t, err) {
+			continue
+		}
+		expected := New(input)
+		assert.EqualValues(t, len(expected), n)
+		assert.True(t, bytes.Equal(expected, buf.Bytes()), "streamed output should match New")
+	}
+}
+
+func TestChunkedBuilderErrors(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := NewChunkedBuilder(&buf).Build(func(cb func(string, interface{})) {
+		cb("b", 1)
+		cb("a", 2)
+	})
+	assert.Error(t, err, "unsorted keys")
+
+	_, err = NewChunkedBuilder(&buf).Build(func(cb func(string, interface{})) {
+		cb(strings.Repeat("k", MaxKeyLen+1), 1)
+	})
+	assert.Error(t, err, "key too long")
+
+	calls := 0
+	_, err = NewChunkedBuilder(&buf).Build(func(cb func(string, interface{})) {
+		calls++
+		cb("a", 1)
+		if calls == 1 {
+			cb("b", 2)
+		}
+	})
+	assert.Error(t, err, "fewer pairs on second pass")
+
+	calls = 0
+	_, err = NewChunkedBuilder(&buf).Build(func(cb func(string, interface{})) {
+		calls++
+		if calls == 1 {
+			cb("a", "short")
+		} else {
+			cb("a", "longer")
+		}
+	})
+	assert.Error(t, err, "changed value length")
+
+	_, err = NewChunkedBuilder(failingWriter{}).Build(sortedIterate(m))
+	assert.Error(t, err)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(b []byte) (int, error) {
+	return 0, errors.New("failed")
+}
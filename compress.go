@@ -0,0 +1,72 @@
+package bytemap
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+)
+
+// TypeCompressed values (see Options.CompressValuesOver) are stored with a 4
+// byte length prefix, followed by the type of the uncompressed value
+// (TypeString or TypeBytes), the 4 byte length of the uncompressed value and
+// the DEFLATE compressed value itself.
+const compressedHeaderLen = SizeValueType + 4
+
+// compress returns the TypeCompressed encoding of the given value, or nil if
+// the value isn't eligible for compression or wouldn't get any smaller.
+func (opts Options) compress(value interface{}) []byte {
+	var t byte
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		t, data = TypeString, []byte(v)
+	case []byte:
+		t, data = TypeBytes, v
+	default:
+		return nil
+	}
+	if len(data) <= opts.CompressValuesOver || uint64(len(data)) > 1<<32-1 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4+compressedHeaderLen))
+	w, _ := flate.NewWriter(&buf, flate.BestSpeed)
+	w.Write(data)
+	w.Close()
+	b := buf.Bytes()
+	if len(b) >= len(data)+2 {
+		// Not worth it
+		return nil
+	}
+	enc.PutUint32(b, uint32(len(b)-4))
+	b[4] = t
+	enc.PutUint32(b[5:], uint32(len(data)))
+	return b
+}
+
+// decodeCompressed decodes the body (without the length prefix) of a
+// TypeCompressed value encoded in the given format, returning nil if it's
+// invalid.
+func decodeCompressed(f format, b []byte) interface{} {
+	if len(b) < compressedHeaderLen {
+		return nil
+	}
+	t := b[0]
+	if t != TypeString && t != TypeBytes {
+		return nil
+	}
+	l := int(f.uint32(b[1:]))
+	r := flate.NewReader(bytes.NewReader(b[compressedHeaderLen:]))
+	// Don't trust the recorded length for sizing the buffer up front, and don't
+	// read more than it.
+	var out bytes.Buffer
+	n, err := io.Copy(&out, io.LimitReader(r, int64(l)+1))
+	if err != nil || int(n) != l {
+		return nil
+	}
+	if t == TypeString {
+		return out.String()
+	}
+	return out.Bytes()
+}
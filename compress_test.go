@@ -0,0 +1,94 @@
+package bytemap
+
+import (
+	"crypto/rand"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var largeJSON = strings.Repeat(`{"id":12345,"name":"some record","tags":["a","b","c"],"active":true},`, 150)
+
+func TestCompressValuesOver(t *testing.T) {
+	random := make([]byte, 2000)
+	rand.Read(random)
+	input := map[string]interface{}{
+		"json":   largeJSON,
+		"bytes":  []byte(largeJSON),
+		"short":  "short string",
+		"random": random,
+		"int":    5,
+	}
+	bm, err := NewWithOptions(input, Options{CompressValuesOver: 1024})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, bm.Validate())
+	plain := New(input)
+	assert.True(t, len(bm) < len(plain)/5, "compressed map should be much smaller, was %d vs %d", len(bm), len(plain))
+
+	assert.Equal(t, input, bm.AsMap())
+	for key, value := range input {
+		assert.Equal(t, value, bm.Get(key), key)
+	}
+
+	typeOf := func(key string) byte {
+		typ, _, _ := bm.GetRaw(key)
+		return typ
+	}
+	assert.EqualValues(t, TypeCompressed, typeOf("json"))
+	assert.EqualValues(t, TypeCompressed, typeOf("bytes"))
+	assert.EqualValues(t, TypeString, typeOf("short"))
+	assert.EqualValues(t, TypeBytes, typeOf("random"), "incompressible values should be stored as is")
+
+	var s string
+	var ok bool
+	bm.IterateTyped(func(key string, v Value) bool {
+		if key == "json" {
+			s, ok = v.Str()
+		}
+		return true
+	})
+	assert.True(t, ok)
+	assert.Equal(t, largeJSON, s)
+
+	// Corrupt the compressed data
+	_, valueOffset, _ := bm.find("json")
+	bm[valueOffset+4+compressedHeaderLen+5] ^= 0xFF
+	assert.Error(t, bm.Validate())
+	assert.NotPanics(t, func() { bm.Get("json") })
+}
+
+func BenchmarkCompressedSize(b *testing.B) {
+	input := map[string]interface{}{"json": largeJSON[:10240], "id": 5}
+	bm, _ := NewWithOptions(input, Options{CompressValuesOver: 1024})
+	b.ReportMetric(float64(len(New(input))), "plain-bytes")
+	b.ReportMetric(float64(len(bm)), "compressed-bytes")
+}
+
+func BenchmarkBuildCompressed(b *testing.B) {
+	input := map[string]interface{}{"json": largeJSON[:10240], "id": 5}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		NewWithOptions(input, Options{CompressValuesOver: 1024})
+	}
+}
+
+func BenchmarkGetCompressed(b *testing.B) {
+	bm, _ := NewWithOptions(map[string]interface{}{"json": largeJSON[:10240], "id": 5}, Options{CompressValuesOver: 1024})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Get("json")
+	}
+}
+
+func BenchmarkGetUncompressed(b *testing.B) {
+	bm := New(map[string]interface{}{"json": largeJSON[:10240], "id": 5})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Get("json")
+	}
+}
@@ -0,0 +1,76 @@
+package bytemap
+
+// Cursor reads the entries of a ByteMap one at a time. It is a pull-based
+// alternative to Iterate, useful when parsing a ByteMap needs to be interleaved
+// with other work.
+type Cursor struct {
+	bm               ByteMap
+	offset           int
+	firstValueOffset int
+	corrupt          bool
+	bigEndian        bool
+}
+
+// entry describes the location of a single key/value pair within a ByteMap.
+type entry struct {
+	keyStart    int
+	keyEnd      int
+	t           byte
+	valueOffset int
+}
+
+// Cursor returns a new Cursor positioned at the first entry of this ByteMap.
+func (bm ByteMap) Cursor() *Cursor {
+	return &Cursor{bm: bm, offset: bm.keysStart(), bigEndian: bm.bigEndian()}
+}
+
+// Next returns the key, type and value bytes of the next entry. ok is false
+// once there are no more entries (or the remainder of the ByteMap can't be
+// parsed). valueBytes alias the underlying ByteMap and are nil for TypeNil.
+func (c *Cursor) Next() (key string, t byte, valueBytes []byte, ok bool) {
+	e, ok := c.next()
+	if !ok {
+		return "", TypeNil, nil, false
+	}
+	if e.t != TypeNil {
+		valueBytes = c.bm.valueBytesAt(e.valueOffset, e.t)
+	}
+	return string(c.bm[e.keyStart:e.keyEnd]), e.t, valueBytes, true
+}
+
+func (c *Cursor) next() (e entry, ok bool) {
+	bm := c.bm
+	if c.firstValueOffset > 0 && c.offset >= c.firstValueOffset {
+		return e, false
+	}
+	keyLen, ok := bm.uint16In(c.bigEndian, c.offset)
+	if !ok {
+		return c.fail()
+	}
+	e.keyStart = c.offset + SizeKeyLen
+	e.keyEnd = e.keyStart + keyLen
+	e.t, ok = bm.byteAt(e.keyEnd)
+	if !ok {
+		return c.fail()
+	}
+	offset := e.keyEnd + SizeValueType
+	if e.t != TypeNil {
+		e.valueOffset, ok = bm.uint32In(c.bigEndian, offset)
+		if !ok {
+			return c.fail()
+		}
+		if c.firstValueOffset == 0 {
+			c.firstValueOffset = e.valueOffset
+		}
+		offset += SizeValueOffset
+	}
+	c.offset = offset
+	return e, true
+}
+
+func (c *Cursor) fail() (entry, bool) {
+	// Running out of bytes exactly at the end of an entry is a clean end
+	c.corrupt = c.offset < len(c.bm)
+	c.offset = len(c.bm)
+	return entry{}, false
+}
@@ -0,0 +1,58 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type cursorEntry struct {
+	key        string
+	valueBytes []byte
+}
+
+func TestCursor(t *testing.T) {
+	bm := New(m)
+	var expected []cursorEntry
+	bm.Iterate(false, true, func(key string, value interface{}, valueBytes []byte) bool {
+		expected = append(expected, cursorEntry{key, valueBytes})
+		return true
+	})
+
+	c := bm.Cursor()
+	var actual []cursorEntry
+	for {
+		key, typ, valueBytes, ok := c.Next()
+		if !ok {
+			break
+		}
+		if typ == TypeNil {
+			assert.Nil(t, valueBytes)
+		}
+		actual = append(actual, cursorEntry{key, valueBytes})
+	}
+	assert.Equal(t, expected, actual)
+
+	_, _, _, ok := c.Next()
+	assert.False(t, ok, "Exhausted cursor should stay exhausted")
+}
+
+func TestCursorAllNil(t *testing.T) {
+	c := New(map[string]interface{}{"a": nil, "b": nil}).Cursor()
+	for _, expectedKey := range []string{"a", "b"} {
+		key, typ, valueBytes, ok := c.Next()
+		assert.True(t, ok)
+		assert.Equal(t, expectedKey, key)
+		assert.EqualValues(t, TypeNil, typ)
+		assert.Nil(t, valueBytes)
+	}
+	_, _, _, ok := c.Next()
+	assert.False(t, ok)
+}
+
+func TestCursorEmpty(t *testing.T) {
+	_, _, _, ok := ByteMap(nil).Cursor().Next()
+	assert.False(t, ok)
+	_, _, _, ok = New(map[string]interface{}{}).Cursor().Next()
+	assert.False(t, ok)
+}
@@ -0,0 +1,35 @@
+package bytemap
+
+// DecodeCache memoizes decoded values, which helps when the same keys of a map
+// are read repeatedly and their values are expensive to decode (e.g. strings
+// and slices).
+//
+// Values are cached by their offset within the map, so a DecodeCache must only
+// ever be used with a single ByteMap. Using it with multiple maps will return
+// values from the wrong map. Since a map can't hold more values than it has
+// keys, the cache never grows beyond the size of its map and doesn't need to
+// evict anything. Cached values are shared between callers, so callers must not
+// modify returned slices.
+//
+// A DecodeCache is not safe for concurrent use. The zero value is ready to use.
+type DecodeCache struct {
+	values map[int]interface{}
+}
+
+// Get gets the value for the given key from bm, decoding it only if it hasn't
+// previously been decoded by this cache.
+func (dc *DecodeCache) Get(bm ByteMap, key string) interface{} {
+	t, valueOffset, found := bm.find(key)
+	if !found || t == TypeNil {
+		return nil
+	}
+	if value, cached := dc.values[valueOffset]; cached {
+		return value
+	}
+	value := bm.decodeValueAt(valueOffset, t)
+	if dc.values == nil {
+		dc.values = make(map[int]interface{})
+	}
+	dc.values[valueOffset] = value
+	return value
+}
@@ -0,0 +1,24 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCache(t *testing.T) {
+	testCountedDecodes = 0
+	bm := New(map[string]interface{}{"a": testCounted("a"), "b": testCounted("b"), "float64s": m["float64s"], "nil": nil})
+	var dc DecodeCache
+	assert.Equal(t, testCounted("a"), dc.Get(bm, "a"))
+	assert.Equal(t, testCounted("a"), dc.Get(bm, "a"))
+	assert.Equal(t, 1, testCountedDecodes, "Cached value should only be decoded once")
+
+	assert.Equal(t, testCounted("b"), dc.Get(bm, "b"))
+	assert.Equal(t, 2, testCountedDecodes)
+	assert.Equal(t, m["float64s"], dc.Get(bm, "float64s"))
+
+	assert.Nil(t, dc.Get(bm, "nil"))
+	assert.Nil(t, dc.Get(bm, "unknown"))
+	assert.Equal(t, 2, testCountedDecodes)
+}
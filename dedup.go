@@ -0,0 +1,48 @@
+package bytemap
+
+// Deduplicate returns a ByteMap with the same contents as this one in which
+// keys whose values have identical encoded bytes point at a single stored copy
+// of that value, which shrinks maps that repeat large values. Keys and values
+// are otherwise copied as-is, so the result keeps this map's format and key
+// order. Since values are no longer stored once per key, maps derived from the
+// result (e.g. with Slice) store them separately again.
+func (bm ByteMap) Deduplicate() ByteMap {
+	var keys [][]byte
+	var valueOffsets []int
+	var values [][]byte
+	keysLen := 0
+	valuesLen := 0
+	stored := make(map[string]int)
+
+	f := bm.format()
+	c := bm.entryCursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			break
+		}
+		key := bm[e.keyStart-SizeKeyLen : e.keyEnd+SizeValueType]
+		if e.t == TypeNil {
+			keys = append(keys, key)
+			valueOffsets = append(valueOffsets, -1)
+			keysLen += len(key)
+			continue
+		}
+		value := bm.valueBytesIn(f, e.valueOffset, e.t)
+		if value == nil {
+			// Value is out of bounds, stop here
+			break
+		}
+		keys = append(keys, key)
+		keysLen += len(key) + SizeValueOffset
+		if valueOffset, found := stored[string(value)]; found {
+			valueOffsets = append(valueOffsets, valueOffset)
+			continue
+		}
+		stored[string(value)] = valuesLen
+		valueOffsets = append(valueOffsets, valuesLen)
+		values = append(values, value)
+		valuesLen += len(value)
+	}
+	return buildFromSliced(bm.header(), keysLen, valuesLen, keys, valueOffsets, values)
+}
@@ -0,0 +1,51 @@
+package bytemap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeduplicate(t *testing.T) {
+	long := strings.Repeat("shared value ", 10)
+	values := map[string]interface{}{"a": long, "b": long, "c": "other", "d": nil, "e": long, "f": 1, "g": 1}
+	bm := New(values)
+	deduped := bm.Deduplicate()
+	assert.NoError(t, deduped.Validate())
+	assert.Equal(t, len(bm)-2*(len(long)+2)-8, len(deduped), "repeated values should be stored once")
+	for key, value := range values {
+		assert.Equal(t, value, deduped.Get(key), key)
+	}
+	assert.True(t, bm.Equal(deduped))
+	assert.Equal(t, bm.SliceExcept("d"), deduped.SliceExcept("d"), "slicing should store shared values separately again")
+	assert.Equal(t, deduped, deduped.Deduplicate())
+
+	unique := New(map[string]interface{}{"a": 1, "b": "b", "c": nil})
+	assert.Equal(t, unique, unique.Deduplicate(), "map without repeated values should be unchanged")
+	deduped = New(m).Deduplicate()
+	assert.NoError(t, deduped.Validate())
+	assert.Equal(t, m, deduped.AsMap())
+
+	ordered := BuildOrdered([]KV{{"z", long}, {"a", long}})
+	deduped = ordered.Deduplicate()
+	assert.True(t, deduped.IsOrdered())
+	assert.Equal(t, []string{"z", "a"}, deduped.Keys())
+	assert.Equal(t, long, deduped.Get("a"))
+}
+
+func BenchmarkDeduplicate(b *testing.B) {
+	values := make(map[string]interface{}, 20)
+	for _, key := range strings.Split("abcdefghijklmnopqrst", "") {
+		values[key] = strings.Repeat("same", 25)
+	}
+	bm := New(values)
+	var deduped ByteMap
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		deduped = bm.Deduplicate()
+	}
+	b.ReportMetric(float64(len(bm)), "bytes/original")
+	b.ReportMetric(float64(len(deduped)), "bytes/deduped")
+}
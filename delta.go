@@ -0,0 +1,120 @@
+package bytemap
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+const sizeDeltaLen = 4
+
+// EncodeDelta writes a delta to w that holds only the entries that differ
+// between prev and next, i.e. the keys that were removed from prev and the
+// entries that were added or changed in next. DecodeDelta reconstructs next
+// from prev and the delta. For streams of similar records (like log lines),
+// writing each record as a delta from the previous one is much smaller than
+// writing the records themselves.
+//
+// A delta consists of a uint32 length, the number of removed keys as a uint16,
+// each removed key as a uint16 length followed by the key, and finally a
+// ByteMap of the added and changed entries.
+func (prev ByteMap) EncodeDelta(next ByteMap, w io.Writer) error {
+	if err := checkNoDictKeys(prev, next); err != nil {
+		return err
+	}
+	a := prev.sortedRawEntries()
+	b := next.sortedRawEntries()
+	var removed []string
+	var changed []rawEntry
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && a[i].key < b[j].key):
+			removed = append(removed, a[i].key)
+			i++
+		case i == len(a) || a[i].key > b[j].key:
+			changed = append(changed, b[j])
+			j++
+		default:
+			if a[i].t != b[j].t || !bytes.Equal(a[i].value, b[j].value) {
+				changed = append(changed, b[j])
+			}
+			i++
+			j++
+		}
+	}
+	if len(removed) > 65535 {
+		return fmt.Errorf("bytemap: too many removed keys for delta: %d", len(removed))
+	}
+
+	changes, err := buildFromRaw(nil, changed)
+	if err != nil {
+		return err
+	}
+	removedLen := 2
+	for _, key := range removed {
+		removedLen += SizeKeyLen + len(key)
+	}
+	delta := make([]byte, sizeDeltaLen+removedLen, sizeDeltaLen+removedLen+len(changes))
+	enc.PutUint32(delta, uint32(removedLen+len(changes)))
+	enc.PutUint16(delta[sizeDeltaLen:], uint16(len(removed)))
+	offset := sizeDeltaLen + 2
+	for _, key := range removed {
+		enc.PutUint16(delta[offset:], uint16(len(key)))
+		offset += SizeKeyLen
+		offset += copy(delta[offset:], key)
+	}
+	delta = append(delta, changes...)
+	_, err = w.Write(delta)
+	return err
+}
+
+// DecodeDelta reads a delta written by EncodeDelta from r and applies it to
+// prev, returning the reconstructed map. The result is always in the default
+// format. If r is at the end of the stream, DecodeDelta returns io.EOF.
+func DecodeDelta(prev ByteMap, r io.Reader) (ByteMap, error) {
+	var lenBuf [sizeDeltaLen]byte
+	if _, err := io.ReadFull(r, lenBuf[:]); err != nil {
+		return nil, err
+	}
+	delta, err := readFrame(r, int(enc.Uint32(lenBuf[:])))
+	if err != nil {
+		return nil, fmt.Errorf("bytemap: unable to read delta: %v", err)
+	}
+
+	if len(delta) < 2 {
+		return nil, fmt.Errorf("bytemap: truncated delta")
+	}
+	numRemoved := int(enc.Uint16(delta))
+	removed := make(map[string]bool, numRemoved)
+	offset := 2
+	for i := 0; i < numRemoved; i++ {
+		if offset+SizeKeyLen > len(delta) {
+			return nil, fmt.Errorf("bytemap: truncated delta")
+		}
+		keyLen := int(enc.Uint16(delta[offset:]))
+		offset += SizeKeyLen
+		if offset+keyLen > len(delta) {
+			return nil, fmt.Errorf("bytemap: truncated delta")
+		}
+		removed[string(delta[offset:offset+keyLen])] = true
+		offset += keyLen
+	}
+	changes := ByteMap(delta[offset:])
+	if err := changes.Validate(); err != nil {
+		return nil, fmt.Errorf("bytemap: invalid delta: %v", err)
+	}
+	if err := checkNoDictKeys(prev, changes); err != nil {
+		return nil, err
+	}
+
+	entries := prev.sortedRawEntries()
+	kept := entries[:0]
+	for _, e := range entries {
+		if !removed[e.key] {
+			kept = append(kept, e)
+		}
+	}
+	merged, _ := mergeRawEntries(kept, changes.rawEntries(), false, nil)
+	return buildFromRaw(nil, merged)
+}
@@ -0,0 +1,74 @@
+package bytemap
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeltaStream(t *testing.T) {
+	records := []ByteMap{
+		New(map[string]interface{}{"host": "a", "status": 200, "path": "/", "bytes": 512, "ua": "curl"}),
+		New(map[string]interface{}{"host": "a", "status": 404, "path": "/missing", "bytes": 512, "ua": "curl"}),
+		New(map[string]interface{}{"host": "a", "status": 404, "path": "/missing", "bytes": nil, "referrer": "x"}),
+	}
+
+	var buf bytes.Buffer
+	var prev ByteMap
+	for _, record := range records {
+		if !assert.NoError(t, prev.EncodeDelta(record, &buf)) {
+			return
+		}
+		prev = record
+	}
+	deltaLen := buf.Len()
+
+	prev = nil
+	for i, expected := range records {
+		decoded, err := DecodeDelta(prev, &buf)
+		if !assert.NoError(t, err, "record %d", i) {
+			return
+		}
+		assert.Equal(t, expected, decoded, "record %d", i)
+		prev = decoded
+	}
+	_, err := DecodeDelta(prev, &buf)
+	assert.Equal(t, io.EOF, err)
+
+	fullLen := 0
+	for _, record := range records {
+		fullLen += len(record)
+	}
+	assert.True(t, deltaLen < fullLen, "deltas (%d bytes) should be smaller than the records (%d bytes)", deltaLen, fullLen)
+}
+
+func TestDeltaUnchanged(t *testing.T) {
+	bm := New(m)
+	var buf bytes.Buffer
+	if assert.NoError(t, bm.EncodeDelta(bm, &buf)) {
+		assert.Equal(t, 4+2, buf.Len(), "identical maps should have an empty delta")
+		decoded, err := DecodeDelta(bm, &buf)
+		if assert.NoError(t, err) {
+			assert.Equal(t, bm, decoded)
+		}
+	}
+}
+
+func TestDecodeDeltaErrors(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, ByteMap(nil).EncodeDelta(New(map[string]interface{}{"a": "b"}), &buf))
+	delta := buf.Bytes()
+	for i := 1; i < len(delta); i++ {
+		_, err := DecodeDelta(nil, bytes.NewReader(delta[:i]))
+		assert.Error(t, err, "truncated to %d", i)
+	}
+	_, err := DecodeDelta(nil, bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 0, 0}))
+	assert.Error(t, err, "length beyond the end of the stream")
+	for _, body := range [][]byte{{}, {1, 0}, {1, 0, 5, 0, 'a'}} {
+		framed := append([]byte{byte(len(body)), 0, 0, 0}, body...)
+		_, err := DecodeDelta(nil, bytes.NewReader(framed))
+		assert.Error(t, err, "%v", body)
+	}
+}
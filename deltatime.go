@@ -0,0 +1,60 @@
+package bytemap
+
+import (
+	"encoding/binary"
+)
+
+// timeAt reads a time value, in nanoseconds since the epoch, at the given
+// offset. n is the number of bytes that the value occupies, or 0 if it runs
+// past the end of the ByteMap.
+func (bm ByteMap) timeAt(f format, offset int) (nanos int64, n int) {
+	if !f.deltaTimes {
+		if bm.offsetTooHigh(offset, 8) {
+			return 0, 0
+		}
+		return int64(f.uint64(bm[offset:])), 8
+	}
+	if offset >= len(bm) {
+		return 0, 0
+	}
+	end := offset + binary.MaxVarintLen64
+	if end > len(bm) {
+		end = len(bm)
+	}
+	delta, n := binary.Varint(bm[offset:end])
+	if n <= 0 {
+		return 0, 0
+	}
+	// Deltas may wrap around for times that are far apart, but wrap back
+	// around here.
+	return f.timeBase + delta, n
+}
+
+// deltaEncodeTimes re-encodes the (default encoded) time values among the
+// given entries as varint deltas against the earliest of them, and returns
+// that base time encoded for storing after the header.
+func deltaEncodeTimes(entries []rawEntry) []byte {
+	var base int64
+	first := true
+	for _, e := range entries {
+		if e.t != TypeTime {
+			continue
+		}
+		nanos := int64(enc.Uint64(e.value))
+		if first || nanos < base {
+			base = nanos
+			first = false
+		}
+	}
+	for i, e := range entries {
+		if e.t != TypeTime {
+			continue
+		}
+		b := make([]byte, binary.MaxVarintLen64)
+		n := binary.PutVarint(b, int64(enc.Uint64(e.value))-base)
+		entries[i].value = b[:n]
+	}
+	b := make([]byte, sizeTimeBase)
+	enc.PutUint64(b, uint64(base))
+	return b
+}
@@ -0,0 +1,79 @@
+package bytemap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var nearTimes = func() map[string]interface{} {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	return map[string]interface{}{
+		"created":   base,
+		"received":  base.Add(3 * time.Millisecond),
+		"parsed":    base.Add(1500 * time.Millisecond),
+		"stored":    base.Add(2 * time.Second),
+		"acked":     base.Add(-7 * time.Microsecond),
+		"name":      "record",
+		"nil":       nil,
+		"sizeBytes": 512,
+	}
+}()
+
+func TestDeltaTimes(t *testing.T) {
+	bm, err := NewWithOptions(nearTimes, Options{DeltaTimes: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, bm.Validate())
+	plain := New(nearTimes)
+	assert.True(t, len(bm) < len(plain), "delta encoded map (%d bytes) should be smaller than plain one (%d bytes)", len(bm), len(plain))
+
+	for key, expected := range nearTimes {
+		actual := bm.Get(key)
+		if expectedTime, ok := expected.(time.Time); ok {
+			actualTime, ok := actual.(time.Time)
+			if assert.True(t, ok, key) {
+				assert.Equal(t, expectedTime.UnixNano(), actualTime.UnixNano(), key)
+			}
+		} else {
+			assert.Equal(t, expected, actual, key)
+		}
+	}
+
+	assert.Equal(t, plain.AsMap(), bm.AsMap())
+	assert.Equal(t, plain, New(nil).Merge(bm), "Merged maps should use default encoding")
+	assert.Equal(t, plain.GetFramedValue("stored"), bm.GetFramedValue("stored"))
+	sliced := bm.Slice(map[string]bool{"stored": true, "name": true})
+	assert.Equal(t, plain.Get("stored"), sliced.Get("stored"))
+	bm.IterateTyped(func(key string, v Value) bool {
+		if tm, ok := v.Time(); ok {
+			assert.Equal(t, plain.Get(key), tm, key)
+		}
+		return true
+	})
+}
+
+func TestDeltaTimesFarApart(t *testing.T) {
+	input := map[string]interface{}{
+		"min": time.Unix(0, -1<<63),
+		"max": time.Unix(0, 1<<63-1),
+		"now": time.Now(),
+	}
+	bm, err := NewWithOptions(input, Options{DeltaTimes: true})
+	if assert.NoError(t, err) {
+		assert.Equal(t, New(input).AsMap(), bm.AsMap())
+	}
+}
+
+func BenchmarkDeltaTimesSize(b *testing.B) {
+	var plainSize, deltaSize int
+	for i := 0; i < b.N; i++ {
+		plainSize = len(New(nearTimes))
+		bm, _ := NewWithOptions(nearTimes, Options{DeltaTimes: true})
+		deltaSize = len(bm)
+	}
+	b.ReportMetric(float64(plainSize), "plain-bytes")
+	b.ReportMetric(float64(deltaSize), "delta-bytes")
+}
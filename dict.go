@@ -0,0 +1,208 @@
+package bytemap
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// errDictKeys is returned when trying to copy the entries of a map built with
+// BuildWithDict, whose keys are meaningless without its KeyDict.
+var errDictKeys = errors.New("bytemap: can't copy the entries of a map built with BuildWithDict")
+
+// KeyDict assigns small numeric IDs to key names so that many maps with the
+// same keys can store the IDs instead of repeating the names (see
+// BuildWithDict). IDs are assigned in the order in which names are first seen
+// and never change, so a KeyDict can be persisted with Names and restored with
+// NewKeyDict. A KeyDict is safe for concurrent use.
+type KeyDict struct {
+	mx    sync.RWMutex
+	ids   map[string]string
+	names []string
+}
+
+// NewKeyDict creates a new KeyDict that assigns IDs to the given names in
+// order, followed by any names it sees later.
+func NewKeyDict(names ...string) *KeyDict {
+	d := &KeyDict{ids: make(map[string]string, len(names))}
+	for _, name := range names {
+		d.idFor(name)
+	}
+	return d
+}
+
+// Names returns the names known to this KeyDict, in the order of their IDs.
+func (d *KeyDict) Names() []string {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	return append([]string(nil), d.names...)
+}
+
+// idFor returns the encoded ID for the given name, assigning a new one if
+// necessary.
+func (d *KeyDict) idFor(name string) string {
+	d.mx.RLock()
+	id, found := d.ids[name]
+	d.mx.RUnlock()
+	if found {
+		return id
+	}
+
+	d.mx.Lock()
+	defer d.mx.Unlock()
+	if id, found := d.ids[name]; found {
+		return id
+	}
+	if d.ids == nil {
+		d.ids = make(map[string]string)
+	}
+	id = string(binary.AppendUvarint(nil, uint64(len(d.names))))
+	d.ids[name] = id
+	d.names = append(d.names, name)
+	return id
+}
+
+// lookupID returns the encoded ID for the given name without assigning one.
+func (d *KeyDict) lookupID(name string) (id string, found bool) {
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	id, found = d.ids[name]
+	return
+}
+
+// nameFor returns the name for the given encoded ID.
+func (d *KeyDict) nameFor(id []byte) (name string, found bool) {
+	i, n := binary.Uvarint(id)
+	if n <= 0 || n != len(id) {
+		return "", false
+	}
+	d.mx.RLock()
+	defer d.mx.RUnlock()
+	if i >= uint64(len(d.names)) {
+		return "", false
+	}
+	return d.names[i], true
+}
+
+// BuildWithDict builds a new ByteMap from the given map that stores its keys as
+// IDs from dict, which assigns IDs to any keys it doesn't know yet. This makes
+// datasets of maps that share the same keys much smaller, since each key takes
+// only a byte or two.
+//
+// The resulting map can only be read with dict (or a KeyDict restored from its
+// Names), using GetWithDict and AsMapWithDict. All other key based accessors
+// treat it as empty. Methods that copy or compare all entries without looking
+// up keys by name (like Merge, TrimFunc, Deduplicate, Shard, Equal and
+// SchemaHash) treat the IDs like keys and keep them, so their results still
+// need dict to be read. Merge and AddNumeric return nil if only one of the maps
+// has dictionary keys and both have entries, since IDs can't be matched with
+// names. Methods that select keys by name (like Slice, Split, Project and
+// StripPrefix) match none of the IDs, and AppendToSlice and EncodeDelta return
+// an error.
+func BuildWithDict(m map[string]interface{}, dict *KeyDict) ByteMap {
+	kvs := make([]KV, 0, len(m))
+	for key, value := range m {
+		kvs = append(kvs, KV{dict.idFor(key), value})
+	}
+	sort.Slice(kvs, func(i, j int) bool {
+		return kvs[i].Key < kvs[j].Key
+	})
+	return build(newHeader(FlagDictKeys), func(cb func(string, interface{})) {
+		for _, kv := range kvs {
+			cb(kv.Key, kv.Value)
+		}
+	}, nil, true)
+}
+
+// HasDictKeys indicates whether this ByteMap was built with BuildWithDict.
+func (bm ByteMap) HasDictKeys() bool {
+	return bm.flags()&FlagDictKeys != 0
+}
+
+// checkNoDictKeys returns errDictKeys if any of the given maps HasDictKeys.
+func checkNoDictKeys(maps ...ByteMap) error {
+	for _, bm := range maps {
+		if bm.HasDictKeys() {
+			return errDictKeys
+		}
+	}
+	return nil
+}
+
+// entriesStart is like keysStart, but returns the start of the ID region for
+// maps built with BuildWithDict, so that methods which copy or compare entries
+// without looking up keys by name can treat their IDs like keys.
+func (bm ByteMap) entriesStart() int {
+	if bm.HasDictKeys() {
+		if _, ok := FormatVersion(bm); ok {
+			return bm.headerLen()
+		}
+	}
+	return bm.keysStart()
+}
+
+// entryCursor returns a Cursor over the entries of this ByteMap starting at
+// entriesStart.
+func (bm ByteMap) entryCursor() *Cursor {
+	return &Cursor{bm: bm, offset: bm.entriesStart(), bigEndian: bm.bigEndian()}
+}
+
+// GetWithDict gets the value for the given key from a map built with
+// BuildWithDict, using the same dict. It returns nil if the key is not found
+// or if this ByteMap wasn't built with a KeyDict.
+func (bm ByteMap) GetWithDict(key string, dict *KeyDict) interface{} {
+	id, found := dict.lookupID(key)
+	if !found {
+		return nil
+	}
+	c := bm.dictCursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return nil
+		}
+		candidate := string(bm[e.keyStart:e.keyEnd])
+		if candidate == id {
+			if e.t == TypeNil {
+				return nil
+			}
+			return bm.decodeValueAt(e.valueOffset, e.t)
+		}
+		if candidate > id {
+			return nil
+		}
+	}
+}
+
+// AsMapWithDict returns a map representation of a map built with
+// BuildWithDict, using the same dict. Keys whose IDs are unknown to dict are
+// skipped. It returns an empty map if this ByteMap wasn't built with a KeyDict.
+func (bm ByteMap) AsMapWithDict(dict *KeyDict) map[string]interface{} {
+	result := make(map[string]interface{}, 10)
+	c := bm.dictCursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return result
+		}
+		name, found := dict.nameFor(bm[e.keyStart:e.keyEnd])
+		if !found {
+			continue
+		}
+		var value interface{}
+		if e.t != TypeNil {
+			value = bm.decodeValueAt(e.valueOffset, e.t)
+		}
+		result[name] = value
+	}
+}
+
+// dictCursor returns a Cursor over the IDs of a map built with BuildWithDict,
+// which is empty for all other maps.
+func (bm ByteMap) dictCursor() *Cursor {
+	if !bm.HasDictKeys() {
+		return &Cursor{bm: bm, offset: len(bm)}
+	}
+	return bm.entryCursor()
+}
@@ -0,0 +1,132 @@
+package bytemap
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildWithDict(t *testing.T) {
+	dict := NewKeyDict()
+	bm := BuildWithDict(m, dict)
+	assert.Len(t, dict.Names(), len(m))
+	assert.True(t, len(bm) < len(New(m)), "dictionary keys should be smaller than full keys")
+	assert.Equal(t, m, bm.AsMapWithDict(dict))
+	for key, value := range m {
+		assert.Equal(t, value, bm.GetWithDict(key, dict), key)
+	}
+	assert.Nil(t, bm.GetWithDict("unknown", dict))
+	assert.Nil(t, bm.Get("string"), "maps with dictionary keys can't be read without the dict")
+	assert.Empty(t, bm.AsMap())
+
+	// Maps built with the same dict share its IDs
+	other := BuildWithDict(map[string]interface{}{"string": "other", "new": 1}, dict)
+	assert.Len(t, dict.Names(), len(m)+1)
+	assert.Equal(t, map[string]interface{}{"string": "other", "new": 1}, other.AsMapWithDict(dict))
+	assert.Nil(t, bm.GetWithDict("new", dict))
+
+	// A restored dict reads the same maps
+	restored := NewKeyDict(dict.Names()...)
+	assert.Equal(t, m, bm.AsMapWithDict(restored))
+	assert.Equal(t, "other", other.GetWithDict("string", restored))
+
+	// Maps without dictionary keys read as empty with a dict
+	assert.Nil(t, New(m).GetWithDict("string", dict))
+	assert.Empty(t, New(m).AsMapWithDict(dict))
+}
+
+func TestBuildWithDictManyKeys(t *testing.T) {
+	dict := NewKeyDict()
+	values := make(map[string]interface{}, 1000)
+	for i := 0; i < 1000; i++ {
+		values[string(rune('a'+i%26))+string(rune('0'+i))] = i
+	}
+	bm := BuildWithDict(values, dict)
+	assert.Equal(t, values, bm.AsMapWithDict(dict))
+	for key, value := range values {
+		assert.Equal(t, value, bm.GetWithDict(key, dict), key)
+	}
+}
+
+func TestDictKeysValidateAndCopy(t *testing.T) {
+	dict := NewKeyDict()
+	bm := BuildWithDict(m, dict)
+	assert.True(t, bm.HasDictKeys())
+	assert.False(t, New(m).HasDictKeys())
+	assert.NoError(t, bm.Validate())
+
+	// Truncate the last value so that the ID region points past the end
+	assert.Error(t, bm[:len(bm)-1].Validate())
+	// Make the first ID sort after the second one
+	unsorted := append(ByteMap(nil), bm...)
+	first := SizeHeader + SizeKeyLen
+	unsorted[first] = 0xff
+	assert.Error(t, unsorted.Validate())
+
+	plain := New(map[string]interface{}{"a": 1})
+	assert.Equal(t, m, bm.Merge(nil).AsMapWithDict(dict))
+	assert.Equal(t, m, ByteMap(nil).WithDefaults(bm).AsMapWithDict(dict))
+	other := BuildWithDict(map[string]interface{}{"string": "other", "new": 1}, dict)
+	expected := make(map[string]interface{}, len(m)+1)
+	for key, value := range m {
+		expected[key] = value
+	}
+	expected["string"], expected["new"] = "other", 1
+	merged := bm.Merge(other)
+	assert.NoError(t, merged.Validate())
+	assert.Equal(t, expected, merged.AsMapWithDict(dict))
+	assert.Nil(t, plain.Merge(bm), "IDs can't be merged with names")
+	assert.Nil(t, bm.AddNumeric(plain), "IDs can't be added to names")
+	sum := BuildWithDict(map[string]interface{}{"n": 1}, dict).AddNumeric(BuildWithDict(map[string]interface{}{"n": 2}, dict))
+	assert.Equal(t, 3, sum.GetWithDict("n", dict))
+
+	assert.Equal(t, New(m).TrimEmpty().AsMap(), bm.TrimEmpty().AsMapWithDict(dict))
+	deduped := bm.Deduplicate()
+	assert.NoError(t, deduped.Validate())
+	assert.Equal(t, m, deduped.AsMapWithDict(dict))
+	sharded := make(map[string]interface{})
+	for _, shard := range bm.Shard(3) {
+		for key, value := range shard.AsMapWithDict(dict) {
+			sharded[key] = value
+		}
+	}
+	assert.Equal(t, m, sharded)
+
+	// Keys selected by name don't match IDs
+	assert.Empty(t, bm.Slice(map[string]bool{"string": true}).AsMapWithDict(dict))
+	assert.Equal(t, New(m).SliceExcept().AsMap(), bm.SliceExcept("string").AsMapWithDict(dict))
+	assert.Empty(t, bm.Project(map[string]string{"string": "s"}).AsMap())
+	assert.Equal(t, bm, bm.StripPrefix("s"))
+	assert.Equal(t, bm, bm.Resort())
+
+	_, err := bm.AppendToSlice("list", "a")
+	assert.Error(t, err)
+	var buf bytes.Buffer
+	assert.Error(t, plain.EncodeDelta(bm, &buf))
+	assert.Error(t, bm.EncodeDelta(plain, &buf))
+	if assert.NoError(t, ByteMap(nil).EncodeDelta(plain, &buf)) {
+		_, err = DecodeDelta(bm, &buf)
+		assert.Error(t, err)
+	}
+}
+
+func TestDictKeysEqual(t *testing.T) {
+	dict := NewKeyDict()
+	a1 := BuildWithDict(map[string]interface{}{"a": 1}, dict)
+	a2 := BuildWithDict(map[string]interface{}{"a": 2}, dict)
+	b1 := BuildWithDict(map[string]interface{}{"b": 1}, dict)
+	assert.True(t, a1.Equal(BuildWithDict(map[string]interface{}{"a": 1}, dict)))
+	assert.False(t, a1.Equal(a2), "different values")
+	assert.True(t, a1.SameSchema(a2))
+	assert.Equal(t, a1.SchemaHash(), a2.SchemaHash())
+	assert.False(t, a1.Equal(b1), "different keys")
+	assert.False(t, a1.SameSchema(b1))
+	assert.NotEqual(t, a1.SchemaHash(), b1.SchemaHash())
+
+	// A plain map whose key has the same bytes as the ID of "a"
+	plain := New(map[string]interface{}{"\x00": 1})
+	assert.False(t, a1.Equal(plain))
+	assert.False(t, a1.SameSchema(plain))
+	assert.NotEqual(t, a1.SchemaHash(), plain.SchemaHash())
+}
@@ -0,0 +1,217 @@
+package bytemap
+
+import (
+	"bytes"
+	"hash/fnv"
+	"sort"
+)
+
+// Equal indicates whether this ByteMap and other have the same keys with equal
+// values. Like EqualOn, values are compared by type and encoded bytes,
+// regardless of the formats of the two maps.
+//
+// Equal checks the cheapest ways in which the maps can differ first. Maps with
+// identical bytes are trivially equal, so that case is checked before anything
+// is decoded. Otherwise, if they have the same format, their key regions must
+// have the same length, then their keys and types must match (see
+// SameSchema), and only then are values compared. It returns false as soon as
+// it finds a difference.
+func (bm ByteMap) Equal(other ByteMap) bool {
+	if bytes.Equal(bm, other) {
+		return true
+	}
+	if bm.IsArray() || other.IsArray() {
+		// Arrays have no keys to compare, but are always laid out identically
+		return false
+	}
+	if bm.HasDictKeys() != other.HasDictKeys() {
+		// IDs can't be compared with names
+		return false
+	}
+	ordered := bm.IsOrdered() || other.IsOrdered()
+	sameFormat := !ordered && bytes.Equal(bm.header(), other.header())
+	if sameFormat && bm.valuesStart() != other.valuesStart() {
+		// Maps with the same format and keys have identical key regions. Their
+		// value regions may still differ in length if one of them shares
+		// values (see Deduplicate).
+		return false
+	}
+	if !bm.SameSchema(other) {
+		return false
+	}
+	if ordered {
+		a := bm.sortedRawEntries()
+		b := other.sortedRawEntries()
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !bytes.Equal(a[i].value, b[i].value) {
+				return false
+			}
+		}
+		return true
+	}
+
+	fa, fb := bm.format(), other.format()
+	ca, cb := bm.entryCursor(), other.entryCursor()
+	for {
+		ea, okA := ca.next()
+		eb, okB := cb.next()
+		if !okA || !okB {
+			return okA == okB
+		}
+		if ea.t == TypeNil {
+			continue
+		}
+		va := bm.defaultEncoding(ea.t, bm.valueBytesIn(fa, ea.valueOffset, ea.t))
+		vb := other.defaultEncoding(eb.t, other.valueBytesIn(fb, eb.valueOffset, eb.t))
+		if !bytes.Equal(va, vb) {
+			return false
+		}
+	}
+}
+
+// EqualOn indicates whether this ByteMap and other have equal values for all of
+// the given keys, ignoring all other keys. Values are equal if they have the
+// same type and the same encoded bytes, regardless of the formats of the two
+// maps. A key that is present in one map (even with a nil value) but absent in
+// the other is unequal, while a key that is absent in both is equal.
+func (bm ByteMap) EqualOn(other ByteMap, keys ...string) bool {
+	a := bm.resolvedValues(keys)
+	b := other.resolvedValues(keys)
+	for i := range keys {
+		if a[i].found != b[i].found || a[i].t != b[i].t || !bytes.Equal(a[i].value, b[i].value) {
+			return false
+		}
+	}
+	return true
+}
+
+type resolvedValue struct {
+	found bool
+	t     byte
+	value []byte
+}
+
+// resolvedValues returns the default encoded value of each of the given keys.
+func (bm ByteMap) resolvedValues(keys []string) []resolvedValue {
+	result := make([]resolvedValue, len(keys))
+	f := bm.format()
+	bm.resolveKeys(keys, func(i int, t byte, valueOffset int) bool {
+		result[i] = resolvedValue{found: true, t: t}
+		if t != TypeNil {
+			result[i].value = bm.defaultEncoding(t, bm.valueBytesIn(f, valueOffset, t))
+		}
+		return true
+	})
+	return result
+}
+
+// valuesStart returns the offset at which the first value is stored, which is
+// where the key region ends, without walking the whole key region.
+func (bm ByteMap) valuesStart() int {
+	c := Cursor{bm: bm, offset: bm.entriesStart(), bigEndian: bm.bigEndian()}
+	for {
+		e, ok := c.next()
+		if !ok {
+			return c.offset
+		}
+		if e.t != TypeNil {
+			return e.valueOffset
+		}
+	}
+}
+
+// SameSchema indicates whether this ByteMap and other have the same keys with
+// the same value types, regardless of their values. Note that a nil value has
+// its own type (TypeNil), so a key that is nil in one map and not the other is
+// a difference in schema. Maps built with BuildWithDict are compared by their
+// key IDs and never have the same schema as maps with plain keys.
+func (bm ByteMap) SameSchema(other ByteMap) bool {
+	if bm.HasDictKeys() != other.HasDictKeys() {
+		return false
+	}
+	if bm.IsOrdered() || other.IsOrdered() {
+		// Keys may be in different orders, so we can't walk in parallel
+		return schemaEqual(bm.schema(), other.schema())
+	}
+	a := bm.entryCursor()
+	b := other.entryCursor()
+	for {
+		ea, okA := a.next()
+		eb, okB := b.next()
+		if !okA || !okB {
+			return okA == okB
+		}
+		if ea.t != eb.t || !bytes.Equal(bm[ea.keyStart:ea.keyEnd], other[eb.keyStart:eb.keyEnd]) {
+			return false
+		}
+	}
+}
+
+// SchemaHash returns a hash of the keys of this ByteMap and the types of their
+// values, ignoring the values themselves, e.g. for bucketing a stream of
+// records by schema. Maps that have the SameSchema have the same SchemaHash,
+// regardless of their format or whether they're ordered. Maps built with
+// BuildWithDict are hashed by their key IDs.
+func (bm ByteMap) SchemaHash() uint64 {
+	h := fnv.New64a()
+	if bm.HasDictKeys() {
+		// Distinguish IDs from plain keys with the same bytes
+		h.Write([]byte{FlagDictKeys})
+	}
+	var keyLen [SizeKeyLen]byte
+	add := func(key []byte, t byte) {
+		// Include the length so that key boundaries are unambiguous
+		enc.PutUint16(keyLen[:], uint16(len(key)))
+		h.Write(keyLen[:])
+		h.Write(key)
+		h.Write([]byte{t})
+	}
+	if bm.IsOrdered() {
+		schema := bm.schema()
+		keys := make([]string, 0, len(schema))
+		for key := range schema {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			add([]byte(key), schema[key])
+		}
+		return h.Sum64()
+	}
+	c := bm.entryCursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return h.Sum64()
+		}
+		add(bm[e.keyStart:e.keyEnd], e.t)
+	}
+}
+
+// schema returns the type of each key in this ByteMap.
+func (bm ByteMap) schema() map[string]byte {
+	result := make(map[string]byte)
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return result
+		}
+		result[string(bm[e.keyStart:e.keyEnd])] = e.t
+	}
+}
+
+func schemaEqual(a map[string]byte, b map[string]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key, t := range a {
+		if other, found := b[key]; !found || other != t {
+			return false
+		}
+	}
+	return true
+}
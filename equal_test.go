@@ -0,0 +1,155 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEqualOn(t *testing.T) {
+	a := New(map[string]interface{}{"a": 1, "b": "b", "c": 3.0, "d": nil, "x": 1})
+	b := New(map[string]interface{}{"a": 1, "b": "b", "c": 4.0, "e": nil, "x": int64(1)})
+
+	assert.True(t, a.EqualOn(b))
+	assert.True(t, a.EqualOn(b, "b", "a"))
+	assert.True(t, a.EqualOn(b, "a", "missing"), "keys missing from both maps should be equal")
+	assert.False(t, a.EqualOn(b, "a", "c"), "differing values should be unequal")
+	assert.False(t, a.EqualOn(b, "d"), "key present in one map only should be unequal")
+	assert.False(t, a.EqualOn(b, "e"), "key present in one map only should be unequal")
+	assert.False(t, a.EqualOn(b, "x"), "values of differing types should be unequal")
+	assert.True(t, a.EqualOn(a, a.Keys()...))
+
+	narrow, err := NewWithOptions(map[string]interface{}{"b": "b"}, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		assert.True(t, a.EqualOn(narrow, "b"), "maps with different formats should compare by value")
+	}
+	ordered := BuildOrdered([]KV{{"c", 4.0}, {"a", 1}})
+	assert.True(t, b.EqualOn(ordered, "a", "c"))
+}
+
+func TestSameSchema(t *testing.T) {
+	a := New(map[string]interface{}{"a": 1, "b": "b", "c": nil})
+	sameSchema := New(map[string]interface{}{"a": 2, "b": "other", "c": nil})
+	differentTypes := New(map[string]interface{}{"a": 1.0, "b": "b", "c": nil})
+	nilDiffers := New(map[string]interface{}{"a": 1, "b": "b", "c": 3})
+	fewerKeys := New(map[string]interface{}{"a": 1, "b": "b"})
+	otherKeys := New(map[string]interface{}{"a": 1, "b": "b", "d": nil})
+
+	assert.True(t, a.SameSchema(a))
+	assert.True(t, a.SameSchema(sameSchema))
+	assert.False(t, a.SameSchema(differentTypes))
+	assert.False(t, a.SameSchema(nilDiffers))
+	assert.False(t, a.SameSchema(fewerKeys))
+	assert.False(t, fewerKeys.SameSchema(a))
+	assert.False(t, a.SameSchema(otherKeys))
+	assert.True(t, New(nil).SameSchema(ByteMap(nil)))
+
+	ordered := BuildOrdered([]KV{{"c", nil}, {"b", "x"}, {"a", 5}})
+	assert.True(t, a.SameSchema(ordered))
+	assert.True(t, ordered.SameSchema(a))
+	assert.False(t, ordered.SameSchema(differentTypes))
+}
+
+func TestSchemaHash(t *testing.T) {
+	a := New(map[string]interface{}{"a": 1, "b": "b", "c": nil})
+	sameSchema := New(map[string]interface{}{"a": 2, "b": "a much longer string", "c": nil})
+	assert.Equal(t, a.SchemaHash(), sameSchema.SchemaHash(), "values should be ignored")
+	assert.Equal(t, a.SchemaHash(), BuildOrdered([]KV{{"c", nil}, {"b", "x"}, {"a", 3}}).SchemaHash(), "order should be ignored")
+	narrow, err := NewWithOptions(map[string]interface{}{"a": 1, "b": "b", "c": nil}, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, a.SchemaHash(), narrow.SchemaHash(), "format should be ignored")
+	}
+
+	assert.NotEqual(t, a.SchemaHash(), New(map[string]interface{}{"a": 1.0, "b": "b", "c": nil}).SchemaHash(), "different type")
+	assert.NotEqual(t, a.SchemaHash(), New(map[string]interface{}{"a": 1, "b": "b", "c": 3}).SchemaHash(), "nil differs")
+	assert.NotEqual(t, a.SchemaHash(), New(map[string]interface{}{"a": 1, "b": "b"}).SchemaHash(), "fewer keys")
+	assert.NotEqual(t, New(map[string]interface{}{"ab": 1}).SchemaHash(), New(map[string]interface{}{"a": 1, "b": 1}).SchemaHash())
+}
+
+func TestEqual(t *testing.T) {
+	bm := New(m)
+	assert.True(t, bm.Equal(bm))
+	assert.True(t, bm.Equal(New(m)))
+	assert.True(t, New(nil).Equal(nil))
+
+	changed := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		changed[key] = value
+	}
+	changed["uint64"] = uint64(1)
+	assert.False(t, bm.Equal(New(changed)), "same length, different value")
+	changed["uint64"] = uint32(1)
+	assert.False(t, bm.Equal(New(changed)), "different type")
+	delete(changed, "uint64")
+	assert.False(t, bm.Equal(New(changed)), "missing key")
+
+	narrow, err := NewWithOptions(m, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		assert.True(t, bm.Equal(narrow), "different formats should compare by value")
+		assert.True(t, narrow.Equal(bm))
+	}
+	assert.True(t, NewArray([]interface{}{1, "a"}).Equal(NewArray([]interface{}{1, "a"})))
+	assert.False(t, NewArray([]interface{}{1, "a"}).Equal(NewArray([]interface{}{1, "b"})))
+	assert.False(t, NewArray(nil).Equal(New(nil)))
+
+	ordered := BuildOrdered([]KV{{"b", 2}, {"a", "a"}})
+	assert.True(t, ordered.Equal(BuildOrdered([]KV{{"b", 2}, {"a", "a"}})), "identical bytes should be equal")
+	assert.True(t, ordered.Equal(New(map[string]interface{}{"a": "a", "b": 2})))
+	assert.False(t, ordered.Equal(New(map[string]interface{}{"a": "b", "b": 2})))
+}
+
+func BenchmarkEqualDifferentLastValue(b *testing.B) {
+	a := New(wideMap)
+	changed := make(map[string]interface{}, len(wideMap))
+	for key, value := range wideMap {
+		changed[key] = value
+	}
+	changed["key099"] = "key09X"
+	other := New(changed)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Equal(other)
+	}
+}
+
+func BenchmarkEqualDifferentSchema(b *testing.B) {
+	a := New(wideMap)
+	changed := make(map[string]interface{}, len(wideMap))
+	for key, value := range wideMap {
+		changed[key] = value
+	}
+	// Same length as the int it replaces, so only the schema differs
+	changed["key000"] = 0.5
+	other := New(changed)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Equal(other)
+	}
+}
+
+func BenchmarkEqualDifferentLength(b *testing.B) {
+	a := New(wideMap)
+	changed := make(map[string]interface{}, len(wideMap))
+	for key, value := range wideMap {
+		changed[key] = value
+	}
+	changed["key000"] = "a longer string"
+	other := New(changed)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Equal(other)
+	}
+}
+
+func BenchmarkEqualIdenticalBytes(b *testing.B) {
+	a := New(wideMap)
+	other := New(wideMap)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.Equal(other)
+	}
+}
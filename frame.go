@@ -0,0 +1,31 @@
+package bytemap
+
+// GetFramedValue returns a self-describing frame holding the value for the
+// given key, which can be decoded with DecodeFramedValue without access to the
+// original map. The frame consists of the value's type followed by its encoded
+// bytes, which include a length prefix for variable length types. It returns
+// nil if the key is not found.
+func (bm ByteMap) GetFramedValue(key string) []byte {
+	t, valueBytes, ok := bm.GetRaw(key)
+	if !ok {
+		return nil
+	}
+	valueBytes = bm.defaultEncoding(t, valueBytes)
+	frame := make([]byte, SizeValueType+len(valueBytes))
+	frame[0] = t
+	copy(frame[SizeValueType:], valueBytes)
+	return frame
+}
+
+// DecodeFramedValue decodes a frame created by GetFramedValue. It returns nil
+// for empty, nil valued or malformed frames.
+func DecodeFramedValue(frame []byte) interface{} {
+	if len(frame) < SizeValueType {
+		return nil
+	}
+	f := defaultFormat
+	if isWideString(frame[0], frame[SizeValueType:]) {
+		f.strLenWidth = 4
+	}
+	return ByteMap(frame[SizeValueType:]).decodeValueIn(f, 0, frame[0])
+}
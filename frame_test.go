@@ -0,0 +1,33 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFramedValue(t *testing.T) {
+	bm := New(m)
+	for key, value := range m {
+		frame := bm.GetFramedValue(key)
+		if assert.NotEmpty(t, frame, key) {
+			assert.Equal(t, value, DecodeFramedValue(frame), key)
+			if value != nil {
+				assert.Equal(t, bm.GetBytes(key), frame[1:], key)
+			}
+		}
+	}
+	assert.Equal(t, []byte{TypeNil}, bm.GetFramedValue("nil"))
+	assert.Nil(t, bm.GetFramedValue("unknown"))
+	assert.Nil(t, DecodeFramedValue(nil))
+	assert.Nil(t, DecodeFramedValue([]byte{TypeString, 5, 0, 'a'}), "Truncated frame")
+}
+
+func TestFramedValueFromOtherFormat(t *testing.T) {
+	bm, err := NewWithOptions(map[string]interface{}{"a": "hello"}, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		frame := bm.GetFramedValue("a")
+		assert.Equal(t, []byte{TypeString, 5, 0, 'h', 'e', 'l', 'l', 'o'}, frame)
+		assert.Equal(t, "hello", DecodeFramedValue(frame))
+	}
+}
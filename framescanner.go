@@ -0,0 +1,116 @@
+package bytemap
+
+import (
+	"fmt"
+	"io"
+)
+
+const (
+	sizeFrameLen = 4
+
+	// frameChunk is how much readFrame reads before it trusts a frame length
+	// enough to grow its buffer.
+	frameChunk = 64 * 1024
+)
+
+// WriteTo writes this ByteMap to w as a frame, i.e. prefixed with its length as
+// a uint32, so that a stream of ByteMaps can be read back with a FrameScanner.
+// It returns the number of bytes written, including the length prefix.
+func (bm ByteMap) WriteTo(w io.Writer) (int64, error) {
+	var frameLen [sizeFrameLen]byte
+	enc.PutUint32(frameLen[:], uint32(len(bm)))
+	n, err := w.Write(frameLen[:])
+	if err != nil {
+		return int64(n), err
+	}
+	m, err := w.Write(bm)
+	return int64(n + m), err
+}
+
+// FrameScanner reads a stream of ByteMaps written with WriteTo. Like
+// bufio.Scanner, it's used by calling Scan until it returns false and reading
+// each ByteMap with Map:
+//
+//	scanner := bytemap.NewFrameScanner(r)
+//	for scanner.Scan() {
+//		bm := scanner.Map()
+//	}
+//	if err := scanner.Err(); err != nil {
+//		...
+//	}
+type FrameScanner struct {
+	r   io.Reader
+	bm  ByteMap
+	err error
+}
+
+// NewFrameScanner creates a new FrameScanner that reads from r.
+func NewFrameScanner(r io.Reader) *FrameScanner {
+	return &FrameScanner{r: r}
+}
+
+// Scan reads the next ByteMap, which is then available from Map. It returns
+// false once the stream ends or reading fails. A stream that ends in the middle
+// of a frame is an error.
+func (s *FrameScanner) Scan() bool {
+	if s.err != nil {
+		return false
+	}
+	s.bm = nil
+	var frameLen [sizeFrameLen]byte
+	if _, err := io.ReadFull(s.r, frameLen[:]); err != nil {
+		if err != io.EOF {
+			s.err = fmt.Errorf("bytemap: truncated frame length: %v", err)
+		} else {
+			s.err = io.EOF
+		}
+		return false
+	}
+	n := int(enc.Uint32(frameLen[:]))
+	bm, err := readFrame(s.r, n)
+	if err != nil {
+		s.err = fmt.Errorf("bytemap: truncated frame of %d bytes: %v", n, err)
+		return false
+	}
+	s.bm = bm
+	return true
+}
+
+// Map returns the ByteMap read by the most recent call to Scan. Each ByteMap
+// has its own backing array, so it remains valid after further calls to Scan.
+func (s *FrameScanner) Map() ByteMap {
+	return s.bm
+}
+
+// Err returns the first error encountered by the FrameScanner, or nil if the
+// stream ended cleanly.
+func (s *FrameScanner) Err() error {
+	if s.err == io.EOF {
+		return nil
+	}
+	return s.err
+}
+
+// readFrame reads a frame of n bytes from r. Since n comes from the stream, it
+// doesn't allocate all n bytes up front, but grows its buffer as data arrives,
+// so that a corrupt or malicious length can't make it allocate much more than
+// the stream actually holds. The result has a capacity of exactly n.
+func readFrame(r io.Reader, n int) ([]byte, error) {
+	b := make([]byte, 0, min(n, frameChunk))
+	for len(b) < n {
+		if len(b) == cap(b) {
+			grown := make([]byte, len(b), min(2*cap(b), n))
+			copy(grown, b)
+			b = grown
+		}
+		read, err := io.ReadFull(r, b[len(b):cap(b)])
+		b = b[:len(b)+read]
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,83 @@
+package bytemap
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFrameScanner(t *testing.T) {
+	maps := []ByteMap{
+		New(map[string]interface{}{"a": 1}),
+		New(m),
+		ByteMap{},
+		BuildOrdered([]KV{{"z", "z"}, {"a", nil}}),
+	}
+	var buf bytes.Buffer
+	for _, bm := range maps {
+		n, err := bm.WriteTo(&buf)
+		assert.NoError(t, err)
+		assert.EqualValues(t, 4+len(bm), n)
+	}
+
+	scanner := NewFrameScanner(&buf)
+	var scanned []ByteMap
+	for scanner.Scan() {
+		scanned = append(scanned, scanner.Map())
+	}
+	assert.NoError(t, scanner.Err())
+	assert.Equal(t, maps, scanned)
+	assert.False(t, scanner.Scan(), "scanner should stay done")
+	assert.Nil(t, scanner.Map())
+}
+
+func TestFrameScannerPartialFrame(t *testing.T) {
+	var buf bytes.Buffer
+	New(map[string]interface{}{"a": 1}).WriteTo(&buf)
+	New(map[string]interface{}{"b": 2}).WriteTo(&buf)
+	frames := buf.Bytes()
+	for _, truncateBy := range []int{1, 8, len(frames)/2 - 2} {
+		scanner := NewFrameScanner(bytes.NewReader(frames[:len(frames)-truncateBy]))
+		assert.True(t, scanner.Scan())
+		assert.Equal(t, 1, scanner.Map().Get("a"))
+		assert.False(t, scanner.Scan())
+		assert.Error(t, scanner.Err(), "truncated by %d", truncateBy)
+	}
+
+	scanner := NewFrameScanner(bytes.NewReader(nil))
+	assert.False(t, scanner.Scan())
+	assert.NoError(t, scanner.Err())
+
+	// A huge length shouldn't be allocated before the data arrives
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	scanner = NewFrameScanner(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff, 1, 2, 3}))
+	assert.False(t, scanner.Scan())
+	runtime.ReadMemStats(&after)
+	assert.Error(t, scanner.Err())
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+}
+
+func TestReadFrame(t *testing.T) {
+	data := make([]byte, 3*frameChunk+1)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	b, err := readFrame(bytes.NewReader(data), len(data))
+	if assert.NoError(t, err) {
+		assert.Equal(t, data, b)
+		assert.Equal(t, len(data), cap(b))
+	}
+	_, err = readFrame(bytes.NewReader(data), len(data)+1)
+	assert.Error(t, err)
+	b, err = readFrame(bytes.NewReader(nil), 0)
+	assert.NoError(t, err)
+	assert.Empty(t, b)
+}
+
+func TestWriteToFailingWriter(t *testing.T) {
+	_, err := New(m).WriteTo(failingWriter{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,103 @@
+package bytemap
+
+import (
+	"math"
+)
+
+// GetOr gets the value for the given key as a T, or returns def if the key is
+// not found, is nil or holds a value of a type other than T. For example:
+//
+//	port := bytemap.GetOr(bm, "port", 8080)
+//
+// Numeric and bool values are decoded directly into the result, so reading
+// them doesn't allocate.
+func GetOr[T any](bm ByteMap, key string, def T) T {
+	t, valueOffset, found := bm.find(key)
+	if !found || t == TypeNil {
+		return def
+	}
+	var result T
+	valueBytes := bm.valueBytesAt(valueOffset, t)
+	if valueBytes != nil && decodeInto(bm.format(), &result, t, valueBytes) {
+		return result
+	}
+	if value, ok := bm.decodeValueAt(valueOffset, t).(T); ok {
+		return value
+	}
+	return def
+}
+
+// decodeInto decodes valueBytes of type t, encoded in format f, into dst, which
+// must be a pointer, without boxing the value. It returns false if dst doesn't
+// point to the Go type corresponding to t or t isn't one of the supported
+// types.
+func decodeInto(f format, dst interface{}, t byte, valueBytes []byte) bool {
+	switch d := dst.(type) {
+	case *bool:
+		if t == TypeBool {
+			*d = valueBytes[0] == 1
+			return true
+		}
+	case *byte:
+		if t == TypeByte {
+			*d = valueBytes[0]
+			return true
+		}
+	case *uint16:
+		if t == TypeUInt16 {
+			*d = f.uint16(valueBytes)
+			return true
+		}
+	case *uint32:
+		if t == TypeUInt32 {
+			*d = f.uint32(valueBytes)
+			return true
+		}
+	case *uint64:
+		if t == TypeUInt64 {
+			*d = f.uint64(valueBytes)
+			return true
+		}
+	case *uint:
+		if t == TypeUInt {
+			*d = uint(f.uint64(valueBytes))
+			return true
+		}
+	case *int8:
+		if t == TypeInt8 {
+			*d = int8(valueBytes[0])
+			return true
+		}
+	case *int16:
+		if t == TypeInt16 {
+			*d = int16(f.uint16(valueBytes))
+			return true
+		}
+	case *int32:
+		if t == TypeInt32 {
+			*d = int32(f.uint32(valueBytes))
+			return true
+		}
+	case *int64:
+		if t == TypeInt64 {
+			*d = int64(f.uint64(valueBytes))
+			return true
+		}
+	case *int:
+		if t == TypeInt {
+			*d = int(f.uint64(valueBytes))
+			return true
+		}
+	case *float32:
+		if t == TypeFloat32 {
+			*d = math.Float32frombits(f.uint32(valueBytes))
+			return true
+		}
+	case *float64:
+		if t == TypeFloat64 {
+			*d = math.Float64frombits(f.uint64(valueBytes))
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+package bytemap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOr(t *testing.T) {
+	bm := New(m)
+	assert.Equal(t, m["int"], GetOr(bm, "int", 5))
+	assert.Equal(t, m["float64"], GetOr(bm, "float64", 1.5))
+	assert.Equal(t, m["uint16"], GetOr(bm, "uint16", uint16(5)))
+	assert.Equal(t, m["string"], GetOr(bm, "string", "default"))
+	assert.Equal(t, m["bool"], GetOr(bm, "bool", false))
+	assert.Equal(t, m["time"], GetOr(bm, "time", time.Time{}))
+	assert.Equal(t, m["float64s"], GetOr(bm, "float64s", []float64{}))
+
+	// Absent and nil keys
+	assert.Equal(t, 8080, GetOr(bm, "unknown", 8080))
+	assert.Equal(t, 8080, GetOr(bm, "nil", 8080))
+
+	// Type mismatches
+	assert.Equal(t, 8080, GetOr(bm, "string", 8080))
+	assert.Equal(t, 8080, GetOr(bm, "int64", 8080), "int64 is not an int")
+	assert.Equal(t, "default", GetOr(bm, "int", "default"))
+	assert.Equal(t, []int{1}, GetOr(bm, "float64s", []int{1}))
+}
+
+func TestGetOrNoAllocs(t *testing.T) {
+	bm := New(m)
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		GetOr(bm, "int", 5)
+		GetOr(bm, "float64", 1.5)
+		GetOr(bm, "uint64", uint64(5))
+	}))
+}
@@ -0,0 +1,283 @@
+package bytemap
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// ByteMaps may optionally start with a header that describes how the rest of
+// the map is laid out. Headerless maps begin directly with the length of their
+// first key. A header begins with a key length of math.MaxUint16, which is
+// reserved for this purpose, followed by a version byte and a flags byte.
+//
+//	+--------+--------+---------+-------+
+//	| 0xFF   | 0xFF   | version | flags |
+//	+--------+--------+---------+-------+
+//
+// Value offsets in maps with a header are absolute, i.e. they include the
+// length of the header.
+//
+// Headerless maps are format version 0 and maps with a header carry their
+// format version in the header (see FormatVersion). Because of the sentinel,
+// version 0 maps can't start with a key that is exactly math.MaxUint16 bytes
+// long, so maps whose first key is that long are always built with a header.
+//
+// This is a compatibility break: every uint16 is a valid key length in
+// version 0, so no sentinel is free of conflicts. Maps written before headers
+// were introduced whose first key is exactly math.MaxUint16 bytes long are
+// misread as having a header. Such maps were never realistic, and all other
+// version 0 maps read as before.
+const (
+	headerSentinel = math.MaxUint16
+	headerVersion  = 1
+
+	SizeHeader = 4
+)
+
+const (
+	// FlagOrdered indicates that the keys in the map are stored in insertion
+	// order rather than lexicographically sorted order.
+	FlagOrdered = 1 << iota
+
+	// FlagArray indicates that the map is a positional array without keys (see
+	// NewArray).
+	FlagArray
+
+	// FlagStringLen1 indicates that the lengths of string values are stored in
+	// 1 byte rather than the default 2 bytes.
+	FlagStringLen1
+
+	// FlagStringLen4 indicates that the lengths of string values are stored in
+	// 4 bytes rather than the default 2 bytes.
+	FlagStringLen4
+
+	// FlagDeltaTimes indicates that time values are stored as varint deltas
+	// against a base time, which is stored in the sizeTimeBase bytes following
+	// the header (see Options.DeltaTimes).
+	FlagDeltaTimes
+
+	// FlagSchemaVersion indicates that the map carries a user-defined schema
+	// version, which is stored in the sizeSchemaVersion bytes following the
+	// header and time base (see Options.SchemaVersion).
+	FlagSchemaVersion
+
+	// FlagBigEndian indicates that all multi-byte integers following the
+	// header (key lengths, value offsets, length prefixes and numeric values)
+	// are stored in big-endian rather than little-endian byte order. The header
+	// itself reads the same in both byte orders.
+	FlagBigEndian
+
+	// FlagDictKeys indicates that keys are stored as IDs from a KeyDict rather
+	// than as strings (see BuildWithDict). Such maps can only be read with
+	// their KeyDict.
+	FlagDictKeys
+)
+
+const (
+	sizeTimeBase      = 8
+	sizeSchemaVersion = 2
+)
+
+// keyLooksLikeHeader indicates whether the given key would be mistaken for a
+// header if it were the first key of a headerless map. Maps whose first key is
+// this long are built with an empty header instead.
+func keyLooksLikeHeader(key string) bool {
+	return len(key) == headerSentinel
+}
+
+func newHeader(flags byte) []byte {
+	h := make([]byte, SizeHeader)
+	enc.PutUint16(h, headerSentinel)
+	h[2] = headerVersion
+	h[3] = flags
+	return h
+}
+
+// hasHeader indicates whether or not this ByteMap starts with a header.
+func (bm ByteMap) hasHeader() bool {
+	return len(bm) >= SizeHeader && enc.Uint16(bm) == headerSentinel
+}
+
+// headerLen returns the length of the header (including the time base and
+// schema version, if any), or 0 if this ByteMap has none.
+func (bm ByteMap) headerLen() int {
+	if !bm.hasHeader() {
+		return 0
+	}
+	return bm.schemaVersionOffset() + bm.schemaVersionLen()
+}
+
+// schemaVersionOffset returns the offset at which the schema version is (or
+// would be) stored, following the time base.
+func (bm ByteMap) schemaVersionOffset() int {
+	if bm.flags()&FlagDeltaTimes != 0 {
+		return SizeHeader + sizeTimeBase
+	}
+	return SizeHeader
+}
+
+func (bm ByteMap) schemaVersionLen() int {
+	if bm.flags()&FlagSchemaVersion != 0 {
+		return sizeSchemaVersion
+	}
+	return 0
+}
+
+// SchemaVersion returns the user-defined schema version with which this
+// ByteMap was built (see Options.SchemaVersion). ok is false if the map wasn't
+// built with a schema version.
+func (bm ByteMap) SchemaVersion() (version uint16, ok bool) {
+	if !bm.hasHeader() || bm.flags()&FlagSchemaVersion == 0 {
+		return 0, false
+	}
+	v, ok := bm.uint16At(bm.schemaVersionOffset())
+	return uint16(v), ok
+}
+
+// header returns the header of this ByteMap (which is empty for headerless
+// maps), truncated to the length of the map if necessary.
+func (bm ByteMap) header() []byte {
+	n := bm.headerLen()
+	if n > len(bm) {
+		n = len(bm)
+	}
+	return bm[:n]
+}
+
+// FormatVersion returns the format version of the given ByteMap, which is 0 for
+// headerless maps and the version recorded in the header otherwise. ok is false
+// if the map has a header of a version that this package can't read, or if the
+// map starts with the header sentinel but is too short to hold a header.
+func FormatVersion(bm ByteMap) (version int, ok bool) {
+	if len(bm) < SizeKeyLen || enc.Uint16(bm) != headerSentinel {
+		return 0, true
+	}
+	if len(bm) < SizeHeader {
+		return 0, false
+	}
+	version = int(bm[2])
+	return version, version == headerVersion
+}
+
+// keysStart returns the offset at which the key region starts. Arrays have no
+// key region and the keys of dictionary-backed maps can't be read without
+// their KeyDict, so for them this is the end of the map.
+func (bm ByteMap) keysStart() int {
+	if bm.hasHeader() && bm[2] != headerVersion {
+		// Unknown format
+		return len(bm)
+	}
+	if bm.IsArray() || bm.flags()&FlagDictKeys != 0 {
+		return len(bm)
+	}
+	return bm.headerLen()
+}
+
+func (bm ByteMap) flags() byte {
+	if !bm.hasHeader() {
+		return 0
+	}
+	return bm[3]
+}
+
+// ByteOrder returns the byte order in which this ByteMap stores multi-byte
+// integers (see FlagBigEndian), which is little-endian unless the header says
+// otherwise. Get, Iterate and the other decoding methods take care of the byte
+// order, but methods that return raw value bytes (like GetBytes and
+// IterateValueBytes) return them in this byte order.
+func (bm ByteMap) ByteOrder() binary.ByteOrder {
+	if bm.bigEndian() {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+func (bm ByteMap) bigEndian() bool {
+	return bm.flags()&FlagBigEndian != 0
+}
+
+// format describes how the values in a ByteMap are encoded.
+type format struct {
+	// strLenWidth is the number of bytes used to store the lengths of string
+	// values
+	strLenWidth int
+
+	// deltaTimes indicates whether time values are stored as varint deltas
+	// against timeBase
+	deltaTimes bool
+	timeBase   int64
+
+	// bigEndian indicates that multi-byte integers are big-endian
+	bigEndian bool
+}
+
+func (f format) uint16(b []byte) uint16 {
+	if f.bigEndian {
+		return binary.BigEndian.Uint16(b)
+	}
+	return enc.Uint16(b)
+}
+
+func (f format) uint32(b []byte) uint32 {
+	if f.bigEndian {
+		return binary.BigEndian.Uint32(b)
+	}
+	return enc.Uint32(b)
+}
+
+func (f format) uint64(b []byte) uint64 {
+	if f.bigEndian {
+		return binary.BigEndian.Uint64(b)
+	}
+	return enc.Uint64(b)
+}
+
+// defaultFormat is the format of headerless maps
+var defaultFormat = format{strLenWidth: 2}
+
+// format returns the format in which this ByteMap's values are encoded.
+func (bm ByteMap) format() format {
+	if !bm.hasHeader() {
+		return defaultFormat
+	}
+	f := format{strLenWidth: bm.stringLenWidth(), bigEndian: bm.bigEndian()}
+	if bm.flags()&FlagDeltaTimes != 0 && len(bm) >= SizeHeader+sizeTimeBase {
+		f.deltaTimes = true
+		f.timeBase = int64(f.uint64(bm[SizeHeader:]))
+	}
+	return f
+}
+
+// stringLenWidth returns the number of bytes used to store the lengths of
+// string values in this ByteMap.
+func (bm ByteMap) stringLenWidth() int {
+	flags := bm.flags()
+	switch {
+	case flags&FlagStringLen1 != 0:
+		return 1
+	case flags&FlagStringLen4 != 0:
+		return 4
+	}
+	return 2
+}
+
+// stringLenAt reads a string length at the given offset, assuming that it's
+// encoded in the given format.
+func (bm ByteMap) stringLenAt(f format, offset int) (l int, ok bool) {
+	if bm.offsetTooHigh(offset, f.strLenWidth) {
+		return 0, false
+	}
+	switch f.strLenWidth {
+	case 1:
+		return int(bm[offset]), true
+	case 4:
+		return int(f.uint32(bm[offset:])), true
+	}
+	return int(f.uint16(bm[offset:])), true
+}
+
+// IsOrdered indicates whether this ByteMap stores its keys in insertion order
+// (see BuildOrdered) rather than sorted order.
+func (bm ByteMap) IsOrdered() bool {
+	return bm.flags()&FlagOrdered == FlagOrdered
+}
@@ -0,0 +1,27 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatVersion(t *testing.T) {
+	assertVersion := func(bm ByteMap, expectedVersion int, expectedOK bool, msg string) {
+		version, ok := FormatVersion(bm)
+		assert.Equal(t, expectedVersion, version, msg)
+		assert.Equal(t, expectedOK, ok, msg)
+	}
+
+	assertVersion(nil, 0, true, "empty")
+	assertVersion(New(m), 0, true, "headerless")
+	assertVersion(BuildOrdered([]KV{{"a", 1}}), 1, true, "ordered")
+	assertVersion(NewArray([]interface{}{1}), 1, true, "array")
+	assertVersion(ByteMap{0xFF, 0xFF}, 0, false, "truncated header")
+
+	future := BuildOrdered([]KV{{"a", 1}})
+	future[2] = 7
+	assertVersion(future, 7, false, "unknown version")
+	assert.Nil(t, future.Get("a"), "Maps of unknown versions should not be misread")
+	assert.Empty(t, future.AsMap(), "Maps of unknown versions should not be misread")
+}
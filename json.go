@@ -0,0 +1,143 @@
+package bytemap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// FromJSON builds a ByteMap from the given JSON object. JSON values are mapped
+// to ByteMap values as follows:
+//
+//	null    -> nil
+//	boolean -> bool
+//	number  -> float64
+//	string  -> string
+//	object  -> nested ByteMap
+//	array   -> nested ByteMap array (see NewArray)
+//
+// It returns an error if data isn't a JSON object or if it contains a key or a
+// string that is longer than math.MaxUint16 bytes.
+func FromJSON(data []byte) (ByteMap, error) {
+	return fromJSON(data, false)
+}
+
+// FromJSONUseNumber is like FromJSON, but preserves integers exactly by mapping
+// JSON numbers that are integers representable as an int64 to int64 values.
+// All other numbers are still mapped to float64.
+func FromJSONUseNumber(data []byte) (ByteMap, error) {
+	return fromJSON(data, true)
+}
+
+func fromJSON(data []byte, useNumber bool) (ByteMap, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if useNumber {
+		dec.UseNumber()
+	}
+	var m map[string]interface{}
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("bytemap: unable to decode JSON object: %v", err)
+	}
+	if dec.More() {
+		return nil, fmt.Errorf("bytemap: unexpected data after JSON object")
+	}
+	if m == nil {
+		return nil, fmt.Errorf("bytemap: JSON null isn't an object")
+	}
+	return fromJSONObject(m)
+}
+
+func fromJSONObject(m map[string]interface{}) (ByteMap, error) {
+	for key, value := range m {
+		converted, err := fromJSONValue(value)
+		if err != nil {
+			return nil, err
+		}
+		m[key] = converted
+	}
+	return newStrict(m)
+}
+
+func fromJSONValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		if len(v) > math.MaxUint16 {
+			return nil, fmt.Errorf("bytemap: JSON string starting with %.16q is %d bytes long, which exceeds the maximum of %d bytes", v, len(v), math.MaxUint16)
+		}
+		return v, nil
+	case map[string]interface{}:
+		return fromJSONObject(v)
+	case []interface{}:
+		for i, element := range v {
+			converted, err := fromJSONValue(element)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return NewArray(v), nil
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("bytemap: invalid JSON number %v: %v", v, err)
+		}
+		return f, nil
+	}
+	return value, nil
+}
+
+// PutJSON adds the given key with v marshaled as compact JSON as a TypeJSON
+// value, which allows storing arbitrarily nested structures without converting
+// them to nested ByteMaps. Get decodes TypeJSON values with json.Unmarshal, so
+// objects come back as map[string]interface{}, arrays as []interface{} and all
+// numbers as float64, which loses the original Go types. New and Build don't
+// support those types and store them as nil, so rebuilding a map from AsMap
+// loses TypeJSON values. Copy them with Merge or Project instead, or re-add
+// them with PutJSON.
+func (b *Builder) PutJSON(key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("bytemap: unable to marshal JSON for key %v: %v", key, err)
+	}
+	if uint64(len(data)) > math.MaxUint32 {
+		return fmt.Errorf("bytemap: JSON for key %v is too large", key)
+	}
+	valueBytes := make([]byte, 4+len(data))
+	enc.PutUint32(valueBytes, uint32(len(data)))
+	copy(valueBytes[4:], data)
+	b.putRaw(key, TypeJSON, valueBytes)
+	return nil
+}
+
+// isEmptyJSON indicates whether the given data is valid JSON for null, an
+// empty string, an empty array or an empty object.
+func isEmptyJSON(data []byte) bool {
+	if !json.Valid(data) {
+		return false
+	}
+	switch v := decodeJSON(data).(type) {
+	case nil:
+		return true
+	case string:
+		return v == ""
+	case []interface{}:
+		return len(v) == 0
+	case map[string]interface{}:
+		return len(v) == 0
+	}
+	return false
+}
+
+// decodeJSON decodes the data of a TypeJSON value, returning nil if it isn't
+// valid JSON.
+func decodeJSON(data []byte) interface{} {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil
+	}
+	return value
+}
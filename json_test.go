@@ -0,0 +1,165 @@
+package bytemap
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testJSON = `{
+	"null": null,
+	"bool": true,
+	"int": 5,
+	"float": 5.5,
+	"big": 12345678901234567890,
+	"string": "hello",
+	"object": {"a": 1, "b": {"c": "d"}},
+	"array": [1, "two", null, [3], {"e": false}]
+}`
+
+func TestFromJSON(t *testing.T) {
+	bm, err := FromJSON([]byte(testJSON))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, bm.Validate())
+	assert.Nil(t, bm.Get("null"))
+	assert.Equal(t, true, bm.Get("bool"))
+	assert.Equal(t, float64(5), bm.Get("int"))
+	assert.Equal(t, 5.5, bm.Get("float"))
+	assert.Equal(t, "hello", bm.Get("string"))
+
+	object, ok := bm.Get("object").(ByteMap)
+	if assert.True(t, ok) {
+		assert.Equal(t, float64(1), object.Get("a"))
+		assert.Equal(t, map[string]interface{}{"c": "d"}, object.Get("b").(ByteMap).AsMap())
+	}
+
+	array, ok := bm.Get("array").(ByteMap)
+	if assert.True(t, ok) && assert.True(t, array.IsArray()) {
+		elements := array.AsSlice()
+		if assert.Len(t, elements, 5) {
+			assert.Equal(t, float64(1), elements[0])
+			assert.Equal(t, "two", elements[1])
+			assert.Nil(t, elements[2])
+			assert.Equal(t, []interface{}{float64(3)}, elements[3].(ByteMap).AsSlice())
+			assert.Equal(t, map[string]interface{}{"e": false}, elements[4].(ByteMap).AsMap())
+		}
+	}
+}
+
+func TestFromJSONUseNumber(t *testing.T) {
+	bm, err := FromJSONUseNumber([]byte(testJSON))
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, int64(5), bm.Get("int"))
+	assert.Equal(t, 5.5, bm.Get("float"))
+	assert.Equal(t, 12345678901234567890.0, bm.Get("big"))
+	assert.Equal(t, int64(1), bm.Get("object").(ByteMap).Get("a"))
+}
+
+func TestFromJSONInvalid(t *testing.T) {
+	for _, data := range []string{"", "[1]", "5", `{"a": }`, `{} {}`} {
+		_, err := FromJSON([]byte(data))
+		assert.Error(t, err, data)
+	}
+	_, err := FromJSON([]byte(`{"` + strings.Repeat("k", MaxKeyLen+1) + `": 1}`))
+	assert.Error(t, err, "key too long")
+	_, err = FromJSON([]byte("null"))
+	assert.Error(t, err, "null isn't an object")
+
+	longest := strings.Repeat("s", math.MaxUint16)
+	bm, err := FromJSON([]byte(`{"s": "` + longest + `", "a": ["` + longest + `"]}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, longest, bm.Get("s"))
+	}
+	for _, data := range []string{`{"s": "` + longest + `s"}`, `{"a": ["` + longest + `s"]}`, `{"o": {"s": "` + longest + `s"}}`} {
+		_, err = FromJSON([]byte(data))
+		assert.Error(t, err, "string too long")
+	}
+}
+
+func TestPutJSON(t *testing.T) {
+	nested := map[string]interface{}{
+		"name": "bob",
+		"tags": []interface{}{"a", 1.5, true, nil, map[string]interface{}{"deep": []interface{}{1.0, 2.0}}},
+		"address": map[string]interface{}{
+			"city": "Springfield",
+			"zip":  12345.0,
+		},
+	}
+	b := NewBuilder()
+	b.Put("a", 1)
+	if !assert.NoError(t, b.PutJSON("nested", nested)) {
+		return
+	}
+	if !assert.NoError(t, b.PutJSON("array", []int{1, 2, 3})) {
+		return
+	}
+	assert.Error(t, b.PutJSON("failed", func() {}), "functions can't be marshaled")
+	bm, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, bm.Validate())
+	assert.Equal(t, []string{"a", "array", "nested"}, bm.Keys())
+	assert.Equal(t, nested, bm.Get("nested"))
+	assert.Equal(t, []interface{}{1.0, 2.0, 3.0}, bm.Get("array"), "numbers should decode as float64")
+
+	parsed, err := ParseCanonicalText(bm.CanonicalText())
+	if assert.NoError(t, err) {
+		assert.Equal(t, bm, parsed)
+	}
+	sliced := bm.Slice(map[string]bool{"nested": true})
+	assert.Equal(t, nested, sliced.Get("nested"))
+	assert.Equal(t, nested, bm.Merge(New(map[string]interface{}{"b": 2})).Get("nested"))
+
+	// Corrupt the JSON by truncating its stored length
+	corrupt := append(ByteMap(nil), bm...)
+	_, valueOffset, _ := corrupt.find("array")
+	enc.PutUint32(corrupt[valueOffset:], 2)
+	assert.Error(t, corrupt.Validate())
+}
+
+func TestJSONValues(t *testing.T) {
+	b := NewBuilder()
+	for key, value := range map[string]interface{}{
+		"null":        nil,
+		"emptystring": "",
+		"emptyarray":  []int{},
+		"emptyobject": map[string]int{},
+		"zero":        0,
+		"array":       []interface{}{1, "two"},
+		"object":      map[string]interface{}{"b": 2, "a": []interface{}{true}},
+	} {
+		if !assert.NoError(t, b.PutJSON(key, value)) {
+			return
+		}
+	}
+	bm, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	assert.Equal(t, []string{"array", "object", "zero"}, bm.TrimEmpty().Keys())
+
+	cbor, err := bm.ToCBOR()
+	if assert.NoError(t, err) {
+		decoded, err := FromCBOR(cbor)
+		if assert.NoError(t, err) {
+			assert.Equal(t, []interface{}{1.0, "two"}, decoded.Get("array").(ByteMap).AsSlice())
+			object := decoded.Get("object").(ByteMap)
+			assert.Equal(t, []string{"a", "b"}, object.Keys())
+			assert.Equal(t, []interface{}{true}, object.Get("a").(ByteMap).AsSlice())
+			assert.Equal(t, 2.0, object.Get("b"))
+			assert.Nil(t, decoded.Get("null"))
+		}
+	}
+
+	rebuilt := New(bm.AsMap())
+	assert.Nil(t, rebuilt.Get("object"), "New doesn't support decoded JSON objects")
+	assert.Equal(t, 0.0, rebuilt.Get("zero"))
+}
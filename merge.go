@@ -0,0 +1,212 @@
+package bytemap
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// Merge returns a new ByteMap containing the keys of both this ByteMap and
+// other. For keys that are present in both, the value from other wins. Values
+// are copied without being decoded, but are converted to the default format
+// (see Options.StringLenWidth for strings that don't fit it).
+func (bm ByteMap) Merge(other ByteMap) ByteMap {
+	merged, _ := bm.merge(other, false)
+	return merged
+}
+
+// MergeWithConflicts is like Merge but also returns the sorted list of keys for
+// which other overrode a different value in this ByteMap. Keys for which both
+// maps hold identical values aren't reported.
+func (bm ByteMap) MergeWithConflicts(other ByteMap) (ByteMap, []string) {
+	return bm.merge(other, true)
+}
+
+// WithDefaults returns a new ByteMap containing the keys of both this ByteMap
+// and defaults, where keys that are missing from this ByteMap are filled in
+// from defaults. It is equivalent to defaults.Merge(bm). Keys that are present
+// in this ByteMap with a nil value count as present and aren't filled in.
+func (bm ByteMap) WithDefaults(defaults ByteMap) ByteMap {
+	return defaults.Merge(bm)
+}
+
+func (bm ByteMap) merge(other ByteMap, trackConflicts bool) (ByteMap, []string) {
+	a, b := bm.sortedRawEntries(), other.sortedRawEntries()
+	header, ok := mergedHeader(bm, a, other, b)
+	if !ok {
+		return nil, nil
+	}
+	merged, conflicts := mergeRawEntries(a, b, trackConflicts, nil)
+	return mustBuild(buildFromRaw(header, merged)), conflicts
+}
+
+// mergedHeader returns the header for merging the entries a of bm with the
+// entries b of other, which carries FlagDictKeys if the entries are keyed by
+// dictionary IDs (see BuildWithDict). ok is false if one of them is keyed by
+// IDs and the other by names.
+func mergedHeader(bm ByteMap, a []rawEntry, other ByteMap, b []rawEntry) (header []byte, ok bool) {
+	dictA := bm.HasDictKeys() && len(a) > 0
+	dictB := other.HasDictKeys() && len(b) > 0
+	switch {
+	case (dictA && !dictB && len(b) > 0) || (dictB && !dictA && len(a) > 0):
+		return nil, false
+	case dictA || dictB:
+		return newHeader(FlagDictKeys), true
+	}
+	return nil, true
+}
+
+// mergeRawEntries merges the sorted entries a and b. Entries that have the same
+// key are combined with combine if it's set, otherwise entries from b win.
+func mergeRawEntries(a []rawEntry, b []rawEntry, trackConflicts bool, combine func(a, b rawEntry) rawEntry) ([]rawEntry, []string) {
+	merged := make([]rawEntry, 0, len(a)+len(b))
+	var conflicts []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i].key < b[j].key:
+			merged = append(merged, a[i])
+			i++
+		case a[i].key > b[j].key:
+			merged = append(merged, b[j])
+			j++
+		default:
+			if trackConflicts && (a[i].t != b[j].t || !bytes.Equal(a[i].value, b[j].value)) {
+				conflicts = append(conflicts, b[j].key)
+			}
+			if combine != nil {
+				merged = append(merged, combine(a[i], b[j]))
+			} else {
+				merged = append(merged, b[j])
+			}
+			i++
+			j++
+		}
+	}
+	merged = append(merged, a[i:]...)
+	merged = append(merged, b[j:]...)
+	return merged, conflicts
+}
+
+// AddNumeric returns a new ByteMap that sums the numeric values of keys that
+// are present in both this ByteMap and other, e.g. for rolling up counters.
+// Keys present in only one of the maps are passed through, and for all other
+// keys (where either value isn't numeric or is nil), the value from other wins
+// like with Merge. Integers of the same type are summed in that type, wrapping
+// around on overflow like Go's integer arithmetic, integers of different types
+// are summed as an int64 and all other numbers (including unsigned values that
+// don't fit into an int64) are summed as a float64.
+//
+// Like Merge, the result is sorted and in the default format.
+func (bm ByteMap) AddNumeric(other ByteMap) ByteMap {
+	a, b := bm.sortedRawEntries(), other.sortedRawEntries()
+	header, ok := mergedHeader(bm, a, other, b)
+	if !ok {
+		return nil
+	}
+	merged, _ := mergeRawEntries(a, b, false, addRawEntries)
+	return mustBuild(buildFromRaw(header, merged))
+}
+
+// addRawEntries sums two numeric entries in the default encoding (see
+// AddNumeric), or returns b if either of them isn't numeric.
+func addRawEntries(a rawEntry, b rawEntry) rawEntry {
+	if a.t == TypeNil || b.t == TypeNil {
+		return b
+	}
+	if a.t == b.t && isIntegerType(a.t) {
+		// Adding the unsigned little-endian representations and truncating to
+		// the width of the type gives the right result for signed types too.
+		sum := make([]byte, len(a.value))
+		carry := 0
+		for i := range sum {
+			s := int(a.value[i]) + int(b.value[i]) + carry
+			sum[i] = byte(s)
+			carry = s >> 8
+		}
+		return rawEntry{b.key, b.t, sum}
+	}
+	if x, ok := intValue(defaultFormat, a.t, a.value); ok {
+		if y, ok := intValue(defaultFormat, b.t, b.value); ok {
+			return rawEntry{b.key, TypeInt64, enc.AppendUint64(nil, uint64(x+y))}
+		}
+	}
+	x, okA := floatValue(defaultFormat, a.t, a.value)
+	y, okB := floatValue(defaultFormat, b.t, b.value)
+	if !okA || !okB {
+		return b
+	}
+	return rawEntry{b.key, TypeFloat64, enc.AppendUint64(nil, math.Float64bits(x+y))}
+}
+
+func isIntegerType(t byte) bool {
+	switch t {
+	case TypeByte, TypeUInt16, TypeUInt32, TypeUInt64, TypeUInt,
+		TypeInt8, TypeInt16, TypeInt32, TypeInt64, TypeInt:
+		return true
+	}
+	return false
+}
+
+// AppendToSlice returns a new ByteMap in which the given values are appended
+// to the slice-typed value of key, which may be a []float64, []int, []int8 or
+// []string. If key is absent or nil, a new slice is created whose type is
+// determined by the first value (float64, int, int8 or string). Like Merge,
+// the result is sorted and in the default format.
+//
+// AppendToSlice returns an error (and no map) if key holds a value that isn't
+// one of the above slices or if any of the values doesn't match the slice's
+// element type.
+func (bm ByteMap) AppendToSlice(key string, values ...interface{}) (ByteMap, error) {
+	if err := checkNoDictKeys(bm); err != nil {
+		return nil, err
+	}
+	existing := bm.Get(key)
+	if existing == nil {
+		if len(values) == 0 {
+			return bm.Merge(nil), nil
+		}
+		switch values[0].(type) {
+		case float64:
+			existing = []float64{}
+		case int:
+			existing = []int{}
+		case int8:
+			existing = []int8{}
+		case string:
+			existing = []string{}
+		default:
+			return nil, fmt.Errorf("bytemap: can't create a slice for key %v from a %T", key, values[0])
+		}
+	}
+
+	var appended interface{}
+	var ok bool
+	switch s := existing.(type) {
+	case []float64:
+		appended, ok = appendTyped(s, values)
+	case []int:
+		appended, ok = appendTyped(s, values)
+	case []int8:
+		appended, ok = appendTyped(s, values)
+	case []string:
+		appended, ok = appendTyped(s, values)
+	default:
+		return nil, fmt.Errorf("bytemap: key %v holds a %T, not a slice", key, existing)
+	}
+	if !ok {
+		return nil, fmt.Errorf("bytemap: values for key %v don't match its element type %T", key, existing)
+	}
+	return bm.Merge(New(map[string]interface{}{key: appended})), nil
+}
+
+func appendTyped[T any](s []T, values []interface{}) ([]T, bool) {
+	for _, value := range values {
+		v, ok := value.(T)
+		if !ok {
+			return nil, false
+		}
+		s = append(s, v)
+	}
+	return s, true
+}
@@ -0,0 +1,136 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	a := New(map[string]interface{}{"a": 1, "b": "b", "c": nil, "d": 4.0})
+	b := New(map[string]interface{}{"b": "B", "c": 3, "e": true})
+	assert.Equal(t, New(map[string]interface{}{"a": 1, "b": "B", "c": 3, "d": 4.0, "e": true}), a.Merge(b))
+	assert.Equal(t, a, a.Merge(nil))
+	assert.Equal(t, b, ByteMap(nil).Merge(b))
+}
+
+func TestMergeWithConflicts(t *testing.T) {
+	base := New(map[string]interface{}{
+		"same":        "value",
+		"sameNil":     nil,
+		"different":   1,
+		"retyped":     int64(1),
+		"onlyInBase":  true,
+		"nilToValue":  nil,
+		"valueToNil":  "value",
+		"longer":      "abc",
+		"alsoSame":    2.5,
+		"zzOnlyFirst": 1,
+	})
+	overlay := New(map[string]interface{}{
+		"same":          "value",
+		"sameNil":       nil,
+		"different":     2,
+		"retyped":       1,
+		"nilToValue":    "value",
+		"valueToNil":    nil,
+		"longer":        "abcd",
+		"alsoSame":      2.5,
+		"onlyInOverlay": "new",
+	})
+	merged, conflicts := base.MergeWithConflicts(overlay)
+	assert.Equal(t, []string{"different", "longer", "nilToValue", "retyped", "valueToNil"}, conflicts)
+	assert.Equal(t, base.Merge(overlay), merged)
+	assert.Equal(t, 2, merged.Get("different"))
+	assert.Equal(t, "new", merged.Get("onlyInOverlay"))
+	assert.Equal(t, true, merged.Get("onlyInBase"))
+	assert.Nil(t, merged.Get("valueToNil"))
+}
+
+func TestMergeOrdered(t *testing.T) {
+	a := BuildOrdered([]KV{{"z", 1}, {"a", 1}})
+	b := BuildOrdered([]KV{{"m", 2}, {"a", 2}})
+	assert.Equal(t, New(map[string]interface{}{"a": 2, "m": 2, "z": 1}), a.Merge(b))
+}
+
+func TestWithDefaults(t *testing.T) {
+	bm := New(map[string]interface{}{"host": "example.com", "timeout": nil, "verbose": true})
+	defaults := New(map[string]interface{}{"host": "localhost", "port": 8080, "timeout": 30, "retries": 3})
+	withDefaults := bm.WithDefaults(defaults)
+	assert.Equal(t, map[string]interface{}{
+		"host":    "example.com",
+		"port":    8080,
+		"timeout": nil,
+		"retries": 3,
+		"verbose": true,
+	}, withDefaults.AsMap())
+	assert.True(t, withDefaults.Has("timeout"), "present nil should be kept")
+	assert.Equal(t, bm, bm.WithDefaults(nil))
+	assert.Equal(t, defaults, ByteMap(nil).WithDefaults(defaults))
+}
+
+func TestAppendToSlice(t *testing.T) {
+	bm := New(map[string]interface{}{"fs": []float64{1.5, 2.5}, "s": "string", "n": nil})
+
+	appended, err := bm.AppendToSlice("fs", 3.5, 4.5)
+	if assert.NoError(t, err) {
+		assert.Equal(t, New(map[string]interface{}{"fs": []float64{1.5, 2.5, 3.5, 4.5}, "s": "string", "n": nil}), appended)
+	}
+	assert.Equal(t, []float64{1.5, 2.5}, bm.Get("fs"), "original should be unchanged")
+
+	created, err := bm.AppendToSlice("is", 1)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []int{1}, created.Get("is"))
+	}
+	created, err = bm.AppendToSlice("n", "a", "b")
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"a", "b"}, created.Get("n"))
+	}
+
+	_, err = bm.AppendToSlice("s", "more")
+	assert.Error(t, err, "appending to a non-slice should fail")
+	_, err = bm.AppendToSlice("fs", 1)
+	assert.Error(t, err, "appending an int to a []float64 should fail")
+	_, err = bm.AppendToSlice("missing", true)
+	assert.Error(t, err, "creating a slice of an unsupported type should fail")
+}
+
+func TestAddNumeric(t *testing.T) {
+	a := New(map[string]interface{}{
+		"requests": 10,
+		"errors":   uint16(2),
+		"bytes":    int64(1000),
+		"latency":  1.5,
+		"small":    int8(120),
+		"onlyA":    1,
+		"name":     "a",
+		"nilToNum": nil,
+	})
+	b := New(map[string]interface{}{
+		"requests": 5,
+		"errors":   uint16(1),
+		"bytes":    int32(24),
+		"latency":  2,
+		"small":    int8(10),
+		"onlyB":    2.5,
+		"name":     "b",
+		"nilToNum": 3,
+	})
+	sum := a.AddNumeric(b)
+	assert.NoError(t, sum.Validate())
+	assert.Equal(t, map[string]interface{}{
+		"requests": 15,
+		"errors":   uint16(3),
+		"bytes":    int64(1024),
+		"latency":  3.5,
+		"small":    int8(-126),
+		"onlyA":    1,
+		"onlyB":    2.5,
+		"name":     "b",
+		"nilToNum": 3,
+	}, sum.AsMap())
+
+	negative := New(map[string]interface{}{"requests": -20, "latency": -1.5})
+	assert.Equal(t, map[string]interface{}{"requests": -10, "latency": 0.0}, New(map[string]interface{}{"requests": 10, "latency": 1.5}).AddNumeric(negative).AsMap())
+	assert.Equal(t, a, a.AddNumeric(nil))
+}
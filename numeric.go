@@ -0,0 +1,34 @@
+package bytemap
+
+// toFloat64 converts numeric values (all integer and float types) to float64.
+// ok is false for non-numeric values. Integers with a magnitude above 2^53
+// lose precision in the conversion.
+func toFloat64(value interface{}) (result float64, ok bool) {
+	switch v := value.(type) {
+	case byte:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
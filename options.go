@@ -0,0 +1,249 @@
+package bytemap
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Options controls the format of ByteMaps built with BuildWithOptions. The zero
+// value builds the same (headerless) maps as Build. Options that deviate from
+// the default format are recorded in the map's header, so readers don't need
+// to know which options a map was built with.
+type Options struct {
+	// StringLenWidth is the number of bytes used to store the length of each
+	// string value, which may be 1, 2 or 4. Narrow widths save space in maps
+	// dominated by short strings, wide widths allow strings longer than
+	// math.MaxUint16 bytes. Defaults to 2.
+	//
+	// Methods that copy values into new maps (like Merge, Project and
+	// TrimFunc) store their results in the default format, unless they hold
+	// strings longer than math.MaxUint16 bytes, in which case the result uses
+	// a StringLenWidth of 4 so that those strings are kept intact.
+	StringLenWidth int
+
+	// DeltaTimes stores time values as varint deltas (in nanoseconds) against
+	// a base time that is stored once in the header. This shrinks time values
+	// that are close together from 8 bytes to as little as 1 byte each, at the
+	// cost of the 8 byte base and slightly slower decoding. The base is the
+	// earliest time in the map.
+	DeltaTimes bool
+
+	// DropNil omits keys whose values are nil (or otherwise encode to TypeNil,
+	// like unsupported types) instead of storing them with TypeNil. This saves
+	// the key overhead of such keys, but makes keys with nil values
+	// indistinguishable from absent ones.
+	DropNil bool
+
+	// CompressValuesOver, if positive, stores string and []byte values that
+	// are longer than this many bytes compressed (with DEFLATE) as
+	// TypeCompressed, as long as that actually makes them smaller. Get
+	// transparently decompresses such values, but GetBytes and other raw
+	// accessors return them compressed.
+	CompressValuesOver int
+
+	// SchemaVersion, if non-zero, stamps the map with a user-defined schema
+	// version that readers can check with ByteMap.SchemaVersion, e.g. to
+	// migrate old records. Maps built without a SchemaVersion report none.
+	SchemaVersion uint16
+
+	// MaxKeyLen, if positive, is the length of the longest key that may be
+	// stored, which may not exceed the package-level MaxKeyLen. Defaults to
+	// that. Longer keys are rejected with an error.
+	MaxKeyLen int
+
+	// KeyLess, if set, stores keys in the order defined by KeyLess (e.g. a
+	// natural order in which "item2" comes before "item10") rather than in
+	// lexicographically sorted order, so that iterating yields them in that
+	// order. Keys that KeyLess considers equal stay in sorted order. Such maps
+	// are marked as ordered (see IsOrdered), so lookups can't use the fast path
+	// for sorted maps and always scan the whole key region.
+	KeyLess func(a, b string) bool
+}
+
+// NewWithOptions creates a new ByteMap from the given map using the given
+// Options.
+func NewWithOptions(m map[string]interface{}, opts Options) (ByteMap, error) {
+	return BuildWithOptions(func(cb func(string, interface{})) {
+		for key, value := range m {
+			cb(key, value)
+		}
+	}, opts)
+}
+
+// BuildWithOptions builds a new ByteMap from the key/value pairs yielded by
+// iterate, using the given Options. Unlike Build, pairs may be yielded in any
+// order and iterate is only called once, since the pairs are buffered and
+// sorted before being encoded. It returns an error if the Options are invalid
+// or if a value can't be represented with them.
+func BuildWithOptions(iterate func(func(string, interface{})), opts Options) (ByteMap, error) {
+	flags, err := opts.flags()
+	if err != nil {
+		return nil, err
+	}
+	maxKeyLen := MaxKeyLen
+	if opts.MaxKeyLen > 0 {
+		maxKeyLen = opts.MaxKeyLen
+	}
+
+	var entries []rawEntry
+	iterate(func(key string, value interface{}) {
+		if err != nil {
+			return
+		}
+		if err = checkKeyLen(key, maxKeyLen); err != nil {
+			return
+		}
+		var e rawEntry
+		e, err = opts.encode(key, value)
+		if e.t == TypeNil && opts.DropNil {
+			return
+		}
+		entries = append(entries, e)
+	})
+	if err != nil {
+		return nil, err
+	}
+	sortRawEntries(entries)
+	if opts.KeyLess != nil {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return opts.KeyLess(entries[i].key, entries[j].key)
+		})
+	}
+
+	var header []byte
+	if flags != 0 || len(entries) > 0 && keyLooksLikeHeader(entries[0].key) {
+		header = newHeader(flags)
+	}
+	if opts.DeltaTimes {
+		header = append(header, deltaEncodeTimes(entries)...)
+	}
+	if opts.SchemaVersion != 0 {
+		header = append(header, 0, 0)
+		enc.PutUint16(header[len(header)-sizeSchemaVersion:], opts.SchemaVersion)
+	}
+	return buildFromRaw(header, entries)
+}
+
+func (opts Options) flags() (byte, error) {
+	var flags byte
+	switch opts.StringLenWidth {
+	case 0, 2:
+		// default
+	case 1:
+		flags |= FlagStringLen1
+	case 4:
+		flags |= FlagStringLen4
+	default:
+		return 0, fmt.Errorf("bytemap: invalid StringLenWidth %d, must be 1, 2 or 4", opts.StringLenWidth)
+	}
+	if opts.DeltaTimes {
+		flags |= FlagDeltaTimes
+	}
+	if opts.SchemaVersion != 0 {
+		flags |= FlagSchemaVersion
+	}
+	if opts.KeyLess != nil {
+		flags |= FlagOrdered
+	}
+	if opts.MaxKeyLen > MaxKeyLen {
+		return 0, fmt.Errorf("bytemap: invalid MaxKeyLen %d, must be at most %d", opts.MaxKeyLen, MaxKeyLen)
+	}
+	return flags, nil
+}
+
+// checkKeyLen returns an error if the given key is longer than max bytes.
+func checkKeyLen(key string, max int) error {
+	if len(key) > max {
+		return fmt.Errorf("bytemap: key starting with %.16q is %d bytes long, which exceeds the maximum of %d bytes", key, len(key), max)
+	}
+	return nil
+}
+
+// encode encodes the given value as a rawEntry according to these options.
+func (opts Options) encode(key string, value interface{}) (rawEntry, error) {
+	if opts.CompressValuesOver > 0 {
+		if compressed := opts.compress(value); compressed != nil {
+			return rawEntry{key, TypeCompressed, compressed}, nil
+		}
+	}
+	if s, ok := value.(string); ok {
+		value, err := opts.encodeString(key, s)
+		return rawEntry{key, TypeString, value}, err
+	}
+	b := make([]byte, encodedLength(value))
+	t, n := encodeValue(b, value)
+	return rawEntry{key, t, b[:n]}, nil
+}
+
+func (opts Options) encodeString(key string, s string) ([]byte, error) {
+	w := opts.StringLenWidth
+	if w == 0 {
+		w = 2
+	}
+	var max uint64
+	switch w {
+	case 1:
+		max = math.MaxUint8
+	case 2:
+		max = math.MaxUint16
+	case 4:
+		max = math.MaxUint32
+	}
+	if uint64(len(s)) > max {
+		return nil, fmt.Errorf("bytemap: string value for key %v is %d bytes long, which exceeds the maximum of %d bytes for a StringLenWidth of %d", key, len(s), max, w)
+	}
+
+	b := make([]byte, w+len(s))
+	switch w {
+	case 1:
+		b[0] = byte(len(s))
+	case 2:
+		enc.PutUint16(b, uint16(len(s)))
+	case 4:
+		enc.PutUint32(b, uint32(len(s)))
+	}
+	copy(b[w:], s)
+	return b, nil
+}
+
+// defaultEncoding re-encodes the given value bytes from this ByteMap in the
+// default encoding used by headerless maps, so that they can be copied into
+// other maps. Strings longer than math.MaxUint16 bytes can't be represented in
+// the default encoding and get a 4 byte length instead (see isWideString).
+func (bm ByteMap) defaultEncoding(t byte, value []byte) []byte {
+	if value == nil {
+		// Malformed value
+		return nil
+	}
+	if t == TypeTime && bm.flags()&FlagDeltaTimes != 0 {
+		nanos, _ := ByteMap(value).timeAt(bm.format(), 0)
+		b := make([]byte, 8)
+		enc.PutUint64(b, uint64(nanos))
+		return b
+	}
+	if t != TypeString {
+		if bm.bigEndian() {
+			return littleEndianValue(t, value)
+		}
+		return value
+	}
+	w := bm.stringLenWidth()
+	if w == 2 && !bm.bigEndian() {
+		return value
+	}
+	l := len(value) - w
+	if l > math.MaxUint16 {
+		if w == 4 && !bm.bigEndian() {
+			return value
+		}
+		b := make([]byte, 4+l)
+		enc.PutUint32(b, uint32(l))
+		copy(b[4:], value[w:])
+		return b
+	}
+	b := make([]byte, 2+l)
+	enc.PutUint16(b, uint16(l))
+	copy(b[2:], value[w:])
+	return b
+}
@@ -0,0 +1,253 @@
+package bytemap
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWithOptionsDefault(t *testing.T) {
+	bm, err := NewWithOptions(m, Options{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, New(m), bm)
+	}
+}
+
+func TestStringLenWidth(t *testing.T) {
+	input := map[string]interface{}{
+		"a":     "short",
+		"b":     "",
+		"int":   5,
+		"c":     "another",
+		"nil":   nil,
+		"bytes": []byte("bytes"),
+	}
+	sizes := make(map[int]int)
+	for _, width := range []int{1, 2, 4} {
+		bm, err := NewWithOptions(input, Options{StringLenWidth: width})
+		if !assert.NoError(t, err, "%d", width) {
+			continue
+		}
+		sizes[width] = len(bm)
+		assert.Equal(t, width, bm.stringLenWidth())
+		assert.Equal(t, input, bm.AsMap(), "%d", width)
+		for key, value := range input {
+			assert.Equal(t, value, bm.Get(key), "%d %v", width, key)
+		}
+		assert.Equal(t, map[string]interface{}{"a": "short", "int": 5}, bm.Slice(map[string]bool{"a": true, "int": true}).AsMap(), "%d", width)
+		assert.Equal(t, New(input), New(nil).Merge(bm), "Merged maps should use default encoding")
+		assert.Equal(t, "short", bm.View("a").Get("a"))
+		assert.Equal(t, width+5, len(bm.GetBytes("a")))
+	}
+	// 3 strings, the default width doesn't need a header
+	assert.Equal(t, sizes[2]+SizeHeader-3, sizes[1])
+	assert.Equal(t, sizes[2]+SizeHeader+6, sizes[4])
+}
+
+func TestStringLenWidthTooLong(t *testing.T) {
+	_, err := NewWithOptions(map[string]interface{}{"a": strings.Repeat("x", 256)}, Options{StringLenWidth: 1})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "key a")
+	}
+	_, err = NewWithOptions(map[string]interface{}{"a": strings.Repeat("x", 255)}, Options{StringLenWidth: 1})
+	assert.NoError(t, err)
+	_, err = NewWithOptions(map[string]interface{}{"a": strings.Repeat("x", 65536)}, Options{})
+	assert.Error(t, err)
+
+	long := strings.Repeat("x", 65536)
+	bm, err := NewWithOptions(map[string]interface{}{"a": long}, Options{StringLenWidth: 4})
+	if assert.NoError(t, err) {
+		assert.Equal(t, long, bm.Get("a"))
+	}
+}
+
+func TestWideStringsSurviveCopying(t *testing.T) {
+	long := strings.Repeat("x", 70000)
+	bm, err := NewWithOptions(map[string]interface{}{"p.long": long, "p.short": "short", "empty": ""}, Options{StringLenWidth: 4})
+	if !assert.NoError(t, err) {
+		return
+	}
+	check := func(name string, copied ByteMap, longKey string) {
+		assert.NoError(t, copied.Validate(), name)
+		assert.Equal(t, long, copied.Get(longKey), name)
+		assert.Equal(t, 4, copied.stringLenWidth(), name)
+	}
+	check("Merge", bm.Merge(nil), "p.long")
+	check("WithDefaults", New(map[string]interface{}{"a": "a"}).WithDefaults(bm), "p.long")
+	check("Project", bm.Project(map[string]string{"p.long": "l", "p.short": "s"}), "l")
+	check("TrimEmpty", bm.TrimEmpty(), "p.long")
+	check("StripPrefix", bm.StripPrefix("p."), "long")
+	appended, err := bm.AppendToSlice("list", "a")
+	if assert.NoError(t, err) {
+		check("AppendToSlice", appended, "p.long")
+		assert.Equal(t, []string{"a"}, appended.Get("list"))
+	}
+	parsed, err := ParseCanonicalText(bm.CanonicalText())
+	if assert.NoError(t, err) {
+		check("ParseCanonicalText", parsed, "p.long")
+	}
+	assert.Equal(t, "short", bm.Merge(nil).Get("p.short"))
+	assert.Equal(t, long, DecodeFramedValue(bm.GetFramedValue("p.long")))
+	assert.Equal(t, "short", DecodeFramedValue(bm.GetFramedValue("p.short")))
+
+	var buf bytes.Buffer
+	prev := New(map[string]interface{}{"empty": ""})
+	if assert.NoError(t, prev.EncodeDelta(bm, &buf)) {
+		decoded, err := DecodeDelta(prev, &buf)
+		if assert.NoError(t, err) {
+			check("DecodeDelta", decoded, "p.long")
+			assert.True(t, decoded.Equal(bm))
+		}
+	}
+
+	assert.Equal(t, defaultFormat, bm.Project(map[string]string{"p.short": "s"}).format(), "maps without wide strings use the default format")
+}
+
+func TestInvalidStringLenWidth(t *testing.T) {
+	_, err := NewWithOptions(m, Options{StringLenWidth: 3})
+	assert.Error(t, err)
+}
+
+func TestMaxKeyLen(t *testing.T) {
+	longest := strings.Repeat("k", MaxKeyLen)
+	tooLong := longest + "k"
+	iterate := func(keys ...string) func(func(string, interface{})) {
+		return func(cb func(string, interface{})) {
+			for _, key := range keys {
+				cb(key, 1)
+			}
+		}
+	}
+
+	bm, err := BuildStrict(iterate(longest, "z"), nil, true)
+	if assert.NoError(t, err) {
+		assert.NoError(t, bm.Validate())
+		assert.True(t, bm.hasHeader(), "a first key that looks like a header sentinel should force a header")
+		assert.Equal(t, []string{longest, "z"}, bm.Keys())
+		assert.Equal(t, 1, bm.Get(longest))
+	}
+	assert.Equal(t, bm, New(map[string]interface{}{longest: 1, "z": 1}))
+
+	_, err = BuildStrict(iterate("a", tooLong), nil, true)
+	assert.Error(t, err)
+	assert.Panics(t, func() { Build(iterate(tooLong), nil, true) })
+
+	bm, err = BuildWithOptions(iterate(longest), Options{})
+	if assert.NoError(t, err) {
+		assert.NoError(t, bm.Validate())
+		assert.Equal(t, 1, bm.Get(longest))
+	}
+	_, err = BuildWithOptions(iterate(tooLong), Options{})
+	assert.Error(t, err)
+	_, err = NewWithOptions(map[string]interface{}{"short": 1, "toolong": 2}, Options{MaxKeyLen: 5})
+	assert.Error(t, err)
+	_, err = NewWithOptions(map[string]interface{}{"short": 1}, Options{MaxKeyLen: 5})
+	assert.NoError(t, err)
+	_, err = NewWithOptions(m, Options{MaxKeyLen: MaxKeyLen + 1})
+	assert.Error(t, err)
+
+	bm = New(map[string]interface{}{"a": 1})
+	projected := bm.Project(map[string]string{"a": longest})
+	assert.True(t, projected.hasHeader())
+	assert.Equal(t, 1, projected.Get(longest))
+	assert.Equal(t, projected, projected.Merge(nil))
+	assert.Panics(t, func() { bm.Project(map[string]string{"a": tooLong}) })
+
+	parsed, err := ParseCanonicalText(`"` + longest + `"=int:1`)
+	if assert.NoError(t, err) {
+		assert.True(t, parsed.hasHeader())
+		assert.Equal(t, 1, parsed.Get(longest))
+	}
+	_, err = ParseCanonicalText(`"` + tooLong + `"=int:1`)
+	assert.Error(t, err)
+
+	bytesMap := NewBytes(map[string][]byte{longest: []byte("v"), "z": nil})
+	assert.NoError(t, bytesMap.Validate())
+	assert.Equal(t, New(map[string]interface{}{longest: []byte("v"), "z": nil}), bytesMap)
+	assert.Panics(t, func() { NewBytes(map[string][]byte{tooLong: nil}) })
+
+	ints := FromSortedKeysAndInts([]string{longest, "z"}, []int64{1, 2})
+	assert.NoError(t, ints.Validate())
+	assert.Equal(t, int64(1), ints.Get(longest))
+	assert.Equal(t, 2, ints.Len())
+	assert.Panics(t, func() { FromSortedKeysAndInts([]string{tooLong}, []int64{1}) })
+}
+
+func TestDropNil(t *testing.T) {
+	input := map[string]interface{}{"a": 1, "nil": nil, "unsupported": struct{}{}, "b": "b"}
+	bm, err := NewWithOptions(input, Options{DropNil: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	plain := New(input)
+	assert.Equal(t, []string{"a", "b"}, bm.Keys())
+	assert.False(t, bm.Has("nil"))
+	assert.False(t, bm.Has("unsupported"))
+	assert.NotContains(t, string(bm), "nil")
+	assert.Equal(t, map[string]interface{}{"a": 1, "b": "b"}, bm.AsMap())
+	assert.Equal(t, len(plain)-KeyOverhead("nil", false)-KeyOverhead("unsupported", false), len(bm))
+	assert.Equal(t, New(map[string]interface{}{"a": 1, "b": "b"}), bm, "dropping nils shouldn't require a header")
+}
+
+func TestSchemaVersion(t *testing.T) {
+	_, ok := New(m).SchemaVersion()
+	assert.False(t, ok, "plain map shouldn't have a schema version")
+	_, ok = ByteMap(nil).SchemaVersion()
+	assert.False(t, ok)
+	narrow, err := NewWithOptions(m, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		_, ok = narrow.SchemaVersion()
+		assert.False(t, ok, "map with a header but no schema version shouldn't have one")
+	}
+
+	for _, opts := range []Options{
+		{SchemaVersion: 7},
+		{SchemaVersion: 7, DeltaTimes: true},
+		{SchemaVersion: 7, StringLenWidth: 4, DeltaTimes: true},
+	} {
+		bm, err := NewWithOptions(m, opts)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		assert.NoError(t, bm.Validate())
+		version, ok := bm.SchemaVersion()
+		assert.True(t, ok)
+		assert.EqualValues(t, 7, version)
+		assert.Equal(t, New(m).AsMap(), bm.AsMap())
+		assert.Equal(t, m["string"], bm.Get("string"))
+		sliced := bm.Slice(map[string]bool{"string": true, "int": true})
+		version, _ = sliced.SchemaVersion()
+		assert.EqualValues(t, 7, version, "slicing should keep the schema version")
+		assert.Equal(t, m["int"], sliced.Get("int"))
+	}
+}
+
+func TestKeyLess(t *testing.T) {
+	// Orders keys by length first, so that "item2" comes before "item10"
+	natural := func(a, b string) bool {
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	}
+	bm, err := NewWithOptions(map[string]interface{}{"item10": 10, "item2": 2, "item1": 1, "item20": nil}, Options{KeyLess: natural})
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, bm.Validate())
+	assert.True(t, bm.IsOrdered())
+	assert.Equal(t, []string{"item1", "item2", "item10", "item20"}, bm.Keys())
+	assert.Equal(t, 10, bm.Get("item10"))
+	assert.Equal(t, 2, bm.Get("item2"))
+	assert.True(t, bm.Has("item20"))
+	assert.False(t, bm.Has("item3"))
+
+	var keys []string
+	bm.IterateValues(func(key string, value interface{}) bool {
+		keys = append(keys, key)
+		return true
+	})
+	assert.Equal(t, []string{"item1", "item2", "item10", "item20"}, keys)
+}
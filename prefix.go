@@ -0,0 +1,47 @@
+package bytemap
+
+import (
+	"sort"
+	"strings"
+)
+
+// StripPrefix returns a new ByteMap in which prefix is removed from every key
+// that has it, while all other keys are left unchanged. Values are copied
+// without being decoded.
+//
+// If stripping a key makes it collide with a key that didn't have the prefix
+// (e.g. "a.b" and "b" with prefix "a."), the value of the stripped key wins and
+// the other one is dropped.
+func (bm ByteMap) StripPrefix(prefix string) ByteMap {
+	if bm.HasDictKeys() {
+		// No key has the prefix, since they're all IDs
+		return bm
+	}
+	entries := bm.rawEntries()
+	stripped := make([]bool, len(entries))
+	for i := range entries {
+		if strings.HasPrefix(entries[i].key, prefix) {
+			entries[i].key = entries[i].key[len(prefix):]
+			stripped[i] = true
+		}
+	}
+	order := make([]int, len(entries))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return entries[order[a]].key < entries[order[b]].key
+	})
+
+	result := make([]rawEntry, 0, len(entries))
+	for j, i := range order {
+		if j > 0 && entries[order[j-1]].key == entries[i].key {
+			if stripped[i] {
+				result[len(result)-1] = entries[i]
+			}
+			continue
+		}
+		result = append(result, entries[i])
+	}
+	return mustBuild(buildFromRaw(nil, result))
+}
@@ -0,0 +1,40 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripPrefix(t *testing.T) {
+	bm := New(map[string]interface{}{
+		"user.name": "Bob",
+		"user.age":  42,
+		"user.zip":  nil,
+		"id":        7,
+		"zzz":       "z",
+	})
+	stripped := bm.StripPrefix("user.")
+	assert.NoError(t, stripped.Validate())
+	assert.Equal(t, map[string]interface{}{
+		"name": "Bob",
+		"age":  42,
+		"zip":  nil,
+		"id":   7,
+		"zzz":  "z",
+	}, stripped.AsMap())
+	assert.EqualValues(t, bm, bm.StripPrefix("other."))
+	assert.EqualValues(t, bm, bm.StripPrefix(""))
+}
+
+func TestStripPrefixCollision(t *testing.T) {
+	// The stripped key wins regardless of which sorts first
+	bm := New(map[string]interface{}{"a.b": 1, "b": 2, "a.0": "stripped", "0": "original"})
+	assert.Equal(t, map[string]interface{}{"b": 1, "0": "stripped"}, bm.StripPrefix("a.").AsMap())
+
+	ordered := BuildOrdered([]KV{{"b", 2}, {"a.b", 1}, {"c", 3}})
+	stripped := ordered.StripPrefix("a.")
+	assert.False(t, stripped.IsOrdered())
+	assert.Equal(t, []string{"b", "c"}, stripped.Keys())
+	assert.Equal(t, 1, stripped.Get("b"))
+}
@@ -0,0 +1,43 @@
+package bytemap
+
+// Project returns a new ByteMap that contains only the keys in mapping, each
+// stored under the name that mapping maps it to. The result is sorted by the
+// new names and values are copied without being decoded, so this selects and
+// renames columns in a single pass. If several keys are mapped to the same new
+// name, the one that comes last in this ByteMap wins. Like Build, Project panics
+// if a new name is longer than MaxKeyLen bytes.
+func (bm ByteMap) Project(mapping map[string]string) ByteMap {
+	entries := make([]rawEntry, 0, len(mapping))
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			break
+		}
+		newKey, found := mapping[string(bm[e.keyStart:e.keyEnd])]
+		if !found {
+			continue
+		}
+		entry := rawEntry{key: newKey, t: e.t}
+		if e.t != TypeNil {
+			entry.value = bm.valueBytesAt(e.valueOffset, e.t)
+			if entry.value == nil {
+				// Truncated value, stop here
+				break
+			}
+			entry.value = bm.defaultEncoding(e.t, entry.value)
+		}
+		entries = append(entries, entry)
+	}
+	sortRawEntries(entries)
+
+	result := entries[:0]
+	for _, e := range entries {
+		if len(result) > 0 && result[len(result)-1].key == e.key {
+			result[len(result)-1] = e
+			continue
+		}
+		result = append(result, e)
+	}
+	return mustBuild(buildFromRaw(nil, result))
+}
@@ -0,0 +1,32 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProject(t *testing.T) {
+	bm := New(map[string]interface{}{"a": 1, "b": "b", "c": 3.5, "d": nil, "e": true})
+	projected := bm.Project(map[string]string{
+		"a":       "z",
+		"c":       "m",
+		"d":       "n",
+		"e":       "a",
+		"missing": "x",
+	})
+	assert.NoError(t, projected.Validate())
+	assert.Equal(t, []string{"a", "m", "n", "z"}, projected.Keys(), "keys should be re-sorted by their new names")
+	assert.Equal(t, New(map[string]interface{}{"z": 1, "m": 3.5, "n": nil, "a": true}), projected)
+
+	assert.Equal(t, New(map[string]interface{}{"x": "b"}), bm.Project(map[string]string{"a": "x", "b": "x"}), "last key should win a collision")
+	assert.Equal(t, 0, bm.Project(nil).Len())
+
+	ordered := BuildOrdered([]KV{{"b", 2}, {"a", 1}})
+	assert.Equal(t, New(map[string]interface{}{"x": 1}), ordered.Project(map[string]string{"a": "x", "b": "x"}), "last in stored order should win")
+
+	narrow, err := NewWithOptions(map[string]interface{}{"s": "string"}, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, New(map[string]interface{}{"t": "string"}), narrow.Project(map[string]string{"s": "t"}))
+	}
+}
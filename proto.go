@@ -0,0 +1,55 @@
+package bytemap
+
+import (
+	"fmt"
+	"math"
+)
+
+// ProtoMessage is a protocol buffers message that can marshal and unmarshal
+// itself, like the messages generated by gogo/protobuf. Messages generated by
+// google.golang.org/protobuf don't have these methods, so use the protobytemap
+// package for them. It's a separate module, so that this module stays free of
+// a protobuf dependency.
+type ProtoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal([]byte) error
+}
+
+// PutProto adds the given key with the marshaled form of msg as a TypeProto
+// value. Get returns TypeProto values as the marshaled bytes, while GetProto
+// unmarshals them into a message.
+func (b *Builder) PutProto(key string, msg ProtoMessage) error {
+	data, err := msg.Marshal()
+	if err != nil {
+		return fmt.Errorf("bytemap: unable to marshal proto for key %v: %v", key, err)
+	}
+	if uint64(len(data)) > math.MaxUint32 {
+		return fmt.Errorf("bytemap: proto for key %v is too large", key)
+	}
+	valueBytes := make([]byte, 4+len(data))
+	enc.PutUint32(valueBytes, uint32(len(data)))
+	copy(valueBytes[4:], data)
+	b.putRaw(key, TypeProto, valueBytes)
+	return nil
+}
+
+// GetProto unmarshals the TypeProto value for the given key into msg. It
+// returns an error if the key is not found, doesn't hold a TypeProto value or
+// can't be unmarshaled.
+func (bm ByteMap) GetProto(key string, msg ProtoMessage) error {
+	t, valueOffset, found := bm.find(key)
+	if !found {
+		return fmt.Errorf("bytemap: key %v not found", key)
+	}
+	if t != TypeProto {
+		return fmt.Errorf("bytemap: key %v has type %d, expected %d", key, t, TypeProto)
+	}
+	data, ok := bm.decodeValueAt(valueOffset, t).([]byte)
+	if !ok {
+		return fmt.Errorf("bytemap: proto for key %v is out of bounds", key)
+	}
+	if err := msg.Unmarshal(data); err != nil {
+		return fmt.Errorf("bytemap: unable to unmarshal proto for key %v: %v", key, err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package bytemap
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testMessage is a hand-written protocol buffers message with the fields
+// int64 id = 1 and string name = 2.
+type testMessage struct {
+	ID   int64
+	Name string
+}
+
+func (msg *testMessage) Marshal() ([]byte, error) {
+	b := binary.AppendUvarint(nil, 1<<3|0)
+	b = binary.AppendUvarint(b, uint64(msg.ID))
+	b = binary.AppendUvarint(b, 2<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(msg.Name)))
+	return append(b, msg.Name...), nil
+}
+
+func (msg *testMessage) Unmarshal(b []byte) error {
+	*msg = testMessage{}
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("invalid tag")
+		}
+		b = b[n:]
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errors.New("invalid value")
+		}
+		b = b[n:]
+		switch tag {
+		case 1<<3 | 0:
+			msg.ID = int64(v)
+		case 2<<3 | 2:
+			if uint64(len(b)) < v {
+				return errors.New("truncated string")
+			}
+			msg.Name, b = string(b[:v]), b[v:]
+		default:
+			return errors.New("unknown field")
+		}
+	}
+	return nil
+}
+
+type failingMessage struct{}
+
+func (failingMessage) Marshal() ([]byte, error) { return nil, errors.New("failed") }
+func (failingMessage) Unmarshal([]byte) error   { return errors.New("failed") }
+
+func TestProto(t *testing.T) {
+	msg := &testMessage{ID: 300, Name: "bob"}
+	b := NewBuilder()
+	b.Put("a", 1)
+	if !assert.NoError(t, b.PutProto("msg", msg)) {
+		return
+	}
+	assert.Error(t, b.PutProto("failed", failingMessage{}))
+	bm, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, bm.Validate())
+	assert.Equal(t, []string{"a", "msg"}, bm.Keys())
+
+	var decoded testMessage
+	if assert.NoError(t, bm.GetProto("msg", &decoded)) {
+		assert.Equal(t, *msg, decoded)
+	}
+	marshaled, _ := msg.Marshal()
+	assert.Equal(t, marshaled, bm.Get("msg"))
+
+	assert.Error(t, bm.GetProto("a", &decoded), "wrong type")
+	assert.Error(t, bm.GetProto("missing", &decoded))
+	assert.Error(t, bm.GetProto("msg", failingMessage{}))
+
+	parsed, err := ParseCanonicalText(bm.CanonicalText())
+	if assert.NoError(t, err) {
+		assert.Equal(t, bm, parsed)
+	}
+}
@@ -0,0 +1,17 @@
+module github.com/getlantern/bytemap/protobytemap
+
+go 1.23
+
+require (
+	github.com/getlantern/bytemap v0.0.0
+	github.com/stretchr/testify v1.6.1
+	google.golang.org/protobuf v1.36.5
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
+
+replace github.com/getlantern/bytemap => ../
@@ -0,0 +1,40 @@
+// Package protobytemap stores messages generated by google.golang.org/protobuf
+// in ByteMaps as TypeProto values. It adapts proto.Message to
+// bytemap.ProtoMessage. It has its own go.mod, so that only programs that use
+// it depend on protobuf, not every user of the bytemap module.
+package protobytemap
+
+import (
+	"github.com/getlantern/bytemap"
+	"google.golang.org/protobuf/proto"
+)
+
+// Message adapts the given message to bytemap.ProtoMessage, marshaling it with
+// proto.Marshal and unmarshaling it with proto.Unmarshal.
+func Message(msg proto.Message) bytemap.ProtoMessage {
+	return message{msg}
+}
+
+type message struct {
+	msg proto.Message
+}
+
+func (m message) Marshal() ([]byte, error) {
+	return proto.Marshal(m.msg)
+}
+
+func (m message) Unmarshal(b []byte) error {
+	return proto.Unmarshal(b, m.msg)
+}
+
+// Put adds the given key with the marshaled form of msg to b (see
+// bytemap.Builder.PutProto).
+func Put(b *bytemap.Builder, key string, msg proto.Message) error {
+	return b.PutProto(key, Message(msg))
+}
+
+// Get unmarshals the TypeProto value for the given key from bm into msg (see
+// bytemap.ByteMap.GetProto).
+func Get(bm bytemap.ByteMap, key string, msg proto.Message) error {
+	return bm.GetProto(key, Message(msg))
+}
@@ -0,0 +1,48 @@
+package protobytemap
+
+import (
+	"testing"
+	"time"
+
+	"github.com/getlantern/bytemap"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestPutGet(t *testing.T) {
+	ts := timestamppb.New(time.Date(2014, 02, 05, 17, 6, 3, 9, time.UTC))
+	s, err := structpb.NewStruct(map[string]interface{}{"name": "bob", "tags": []interface{}{"a", 1.5}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	b := bytemap.NewBuilder()
+	b.Put("a", 1)
+	if !assert.NoError(t, Put(b, "ts", ts)) {
+		return
+	}
+	if !assert.NoError(t, Put(b, "struct", s)) {
+		return
+	}
+	bm, err := b.Build()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, bm.Validate())
+
+	var decodedTS timestamppb.Timestamp
+	if assert.NoError(t, Get(bm, "ts", &decodedTS)) {
+		assert.True(t, proto.Equal(ts, &decodedTS))
+	}
+	var decodedStruct structpb.Struct
+	if assert.NoError(t, Get(bm, "struct", &decodedStruct)) {
+		assert.True(t, proto.Equal(s, &decodedStruct))
+	}
+	marshaled, _ := proto.Marshal(ts)
+	assert.Equal(t, marshaled, bm.Get("ts"))
+
+	assert.Error(t, Get(bm, "a", &decodedTS), "wrong type")
+	assert.Error(t, Get(bm, "missing", &decodedTS))
+}
@@ -0,0 +1,132 @@
+package bytemap
+
+import (
+	"math"
+	"sort"
+)
+
+// rawEntry is a key with the type and encoded bytes of its value. rawEntries
+// allow building new maps out of the contents of existing ones without
+// decoding and re-encoding values.
+type rawEntry struct {
+	key   string
+	t     byte
+	value []byte
+}
+
+// rawEntries returns all entries of this ByteMap in stored order, keyed by ID
+// for maps built with BuildWithDict (see entriesStart). Values are
+// always in the default encoding, so that they can be copied into other maps.
+// Unless they had to be re-encoded, the value bytes alias this ByteMap.
+func (bm ByteMap) rawEntries() []rawEntry {
+	var entries []rawEntry
+	c := bm.entryCursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return entries
+		}
+		entry := rawEntry{key: string(bm[e.keyStart:e.keyEnd]), t: e.t}
+		if e.t != TypeNil {
+			entry.value = bm.valueBytesAt(e.valueOffset, e.t)
+			if entry.value == nil {
+				// Truncated value, stop here
+				return entries
+			}
+			entry.value = bm.defaultEncoding(e.t, entry.value)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+// sortedRawEntries is like rawEntries but guarantees that the result is sorted
+// by key, even if this ByteMap IsOrdered.
+func (bm ByteMap) sortedRawEntries() []rawEntry {
+	entries := bm.rawEntries()
+	if bm.IsOrdered() {
+		sortRawEntries(entries)
+	}
+	return entries
+}
+
+func sortRawEntries(entries []rawEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+}
+
+// isWideString indicates whether the given value is a string that is too long
+// for the default encoding, which defaultEncoding stores with a 4 byte length
+// instead. Strings in the default encoding are at most 2+math.MaxUint16 bytes
+// long, so the two can't be confused.
+func isWideString(t byte, value []byte) bool {
+	return t == TypeString && len(value) > 2+math.MaxUint16
+}
+
+// widenStrings converts the given entries to a StringLenWidth of 4 by adding
+// FlagStringLen4 to header and giving all default encoded strings a 4 byte
+// length, which keeps wide strings (see isWideString) intact.
+func widenStrings(header []byte, entries []rawEntry) []byte {
+	if len(header) == 0 {
+		header = newHeader(FlagStringLen4)
+	} else {
+		header = append([]byte(nil), header...)
+		header[3] |= FlagStringLen4
+	}
+	for i, e := range entries {
+		if e.t == TypeString && !isWideString(e.t, e.value) {
+			value := make([]byte, 4+len(e.value)-2)
+			enc.PutUint32(value, uint32(len(e.value)-2))
+			copy(value[4:], e.value[2:])
+			entries[i].value = value
+		}
+	}
+	return header
+}
+
+// buildFromRaw builds a ByteMap from the given entries, which are stored in
+// the given order following the given header. Like Build, it adds an empty
+// header if the first key would otherwise be mistaken for one, and returns an
+// error if any key is longer than MaxKeyLen bytes. If entries in the default
+// encoding hold wide strings (see isWideString), the map is stored with a
+// StringLenWidth of 4.
+func buildFromRaw(header []byte, entries []rawEntry) (ByteMap, error) {
+	if ByteMap(header).stringLenWidth() == 2 {
+		for _, e := range entries {
+			if isWideString(e.t, e.value) {
+				header = widenStrings(header, entries)
+				break
+			}
+		}
+	}
+	if len(header) == 0 && len(entries) > 0 && keyLooksLikeHeader(entries[0].key) {
+		header = newHeader(0)
+	}
+	keysLen := len(header)
+	valuesLen := 0
+	for _, e := range entries {
+		if err := checkKeyLen(e.key, MaxKeyLen); err != nil {
+			return nil, err
+		}
+		keysLen += KeyOverhead(e.key, e.t != TypeNil)
+		valuesLen += len(e.value)
+	}
+
+	bm := make(ByteMap, keysLen+valuesLen)
+	copy(bm, header)
+	keyOffset := len(header)
+	valueOffset := keysLen
+	for _, e := range entries {
+		enc.PutUint16(bm[keyOffset:], uint16(len(e.key)))
+		keyOffset += SizeKeyLen
+		keyOffset += copy(bm[keyOffset:], e.key)
+		bm[keyOffset] = e.t
+		keyOffset += SizeValueType
+		if e.t != TypeNil {
+			enc.PutUint32(bm[keyOffset:], uint32(valueOffset))
+			keyOffset += SizeValueOffset
+			valueOffset += copy(bm[valueOffset:], e.value)
+		}
+	}
+	return bm, nil
+}
@@ -0,0 +1,177 @@
+package bytemap
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// minReadSize is the smallest read that a ReaderMap performs
+const minReadSize = 64
+
+// ReaderMap reads a ByteMap that is stored in an io.ReaderAt, without loading
+// the whole map into memory. On first use, it reads the key region (which
+// precedes all of the values) and keeps it in memory. Values are then read on
+// demand with ranged reads.
+//
+// A ReaderMap is safe for concurrent use, provided that its io.ReaderAt is
+// (as the io.ReaderAt contract requires).
+//
+// Arrays are treated as empty, just like by ByteMap's key based accessors.
+type ReaderMap struct {
+	r    io.ReaderAt
+	size int
+	keys ByteMap
+	f    format
+	once sync.Once
+	err  error
+}
+
+// NewReaderAt creates a new ReaderMap that reads a ByteMap of the given size
+// from r.
+func NewReaderAt(r io.ReaderAt, size int) *ReaderMap {
+	return &ReaderMap{r: r, size: size}
+}
+
+// Get gets the value for the given key, or nil if the key is not found.
+func (rm *ReaderMap) Get(key string) (interface{}, error) {
+	if err := rm.loadKeys(); err != nil {
+		return nil, err
+	}
+	t, valueOffset, found := rm.keys.find(key)
+	if !found || t == TypeNil {
+		return nil, nil
+	}
+	return rm.readValue(valueOffset, t)
+}
+
+// Iterate iterates over the key/value pairs in the map and calls the given
+// callback with each. If the callback returns false, iteration stops even if
+// there remain unread values.
+func (rm *ReaderMap) Iterate(cb func(key string, value interface{}) bool) error {
+	if err := rm.loadKeys(); err != nil {
+		return err
+	}
+	c := rm.keys.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return nil
+		}
+		var value interface{}
+		if e.t != TypeNil {
+			var err error
+			value, err = rm.readValue(e.valueOffset, e.t)
+			if err != nil {
+				return err
+			}
+		}
+		if !cb(string(rm.keys[e.keyStart:e.keyEnd]), value) {
+			return nil
+		}
+	}
+}
+
+// loadKeys reads the header and key region the first time it's called and
+// returns the error (if any) of doing so.
+func (rm *ReaderMap) loadKeys() error {
+	rm.once.Do(func() {
+		rm.err = rm.readKeys()
+	})
+	return rm.err
+}
+
+// readKeys reads the header and key region, doubling the amount read until it
+// contains all of the keys.
+func (rm *ReaderMap) readKeys() (err error) {
+	var buf []byte
+	n := minReadSize
+	for {
+		if n > rm.size {
+			n = rm.size
+		}
+		buf, err = rm.readAt(buf, 0, n)
+		if err != nil {
+			return err
+		}
+		bm := ByteMap(buf)
+		rm.f = bm.format()
+		if n == rm.size {
+			// We've read the whole map
+			rm.keys = bm
+			return nil
+		}
+		if keysEnd, complete := bm.keysEnd(); complete {
+			rm.keys = bm[:keysEnd]
+			return nil
+		}
+		n *= 2
+	}
+}
+
+// readValue reads the value of type t at the given offset, doubling the amount
+// read until it contains the whole value.
+func (rm *ReaderMap) readValue(offset int, t byte) (interface{}, error) {
+	if offset < 0 || offset >= rm.size {
+		return nil, fmt.Errorf("bytemap: value offset %d out of bounds", offset)
+	}
+	var buf []byte
+	n := minReadSize
+	for {
+		if offset+n > rm.size {
+			n = rm.size - offset
+		}
+		var err error
+		buf, err = rm.readAt(buf, offset, n)
+		if err != nil {
+			return nil, err
+		}
+		window := ByteMap(buf)
+		if window.valueBytesIn(rm.f, 0, t) != nil {
+			return window.decodeValueIn(rm.f, 0, t), nil
+		}
+		if offset+n == rm.size {
+			return nil, fmt.Errorf("bytemap: value of type %d at offset %d is invalid or out of bounds", t, offset)
+		}
+		n *= 2
+	}
+}
+
+// readAt extends buf, which holds the bytes starting at offset, to n bytes.
+func (rm *ReaderMap) readAt(buf []byte, offset int, n int) ([]byte, error) {
+	have := len(buf)
+	if n <= have {
+		return buf, nil
+	}
+	extended := make([]byte, n)
+	copy(extended, buf)
+	read, err := rm.r.ReadAt(extended[have:], int64(offset+have))
+	if read < n-have {
+		return nil, fmt.Errorf("bytemap: unable to read at offset %d: %v", offset+have, err)
+	}
+	return extended, nil
+}
+
+// keysEnd returns the offset at which the key region of this ByteMap ends.
+// complete is false if this ByteMap is a prefix of a larger map and doesn't
+// contain the entire key region.
+func (bm ByteMap) keysEnd() (end int, complete bool) {
+	if bm.headerLen() > len(bm) {
+		return 0, false
+	}
+	c := bm.Cursor()
+	for {
+		if _, ok := c.next(); !ok {
+			break
+		}
+	}
+	if c.corrupt || c.firstValueOffset > len(bm) {
+		return 0, false
+	}
+	if c.firstValueOffset == 0 {
+		// All values are nil, so the key region extends to the end of the map,
+		// which may be beyond what we have
+		return 0, false
+	}
+	return c.firstValueOffset, true
+}
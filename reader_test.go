@@ -0,0 +1,109 @@
+package bytemap
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReaderMap(t *testing.T) {
+	large := make(map[string]interface{})
+	for k, v := range m {
+		large[k] = v
+	}
+	for i := 0; i < 100; i++ {
+		large[strings.Repeat("k", i+1)] = i
+	}
+	large["long"] = strings.Repeat("x", 10000)
+
+	narrow, err := NewWithOptions(m, Options{StringLenWidth: 1, DeltaTimes: true})
+	if !assert.NoError(t, err) {
+		return
+	}
+	bms := []ByteMap{
+		New(m),
+		New(large),
+		New(map[string]interface{}{}),
+		New(map[string]interface{}{"a": nil, "b": nil}),
+		narrow,
+		BuildOrdered([]KV{{"z", 1}, {"a", "a"}}),
+	}
+	for i, bm := range bms {
+		rm := NewReaderAt(bytes.NewReader(bm), len(bm))
+		for _, key := range append(bm.Keys(), "missing") {
+			value, err := rm.Get(key)
+			assert.NoError(t, err, "%d %v", i, key)
+			assert.Equal(t, bm.Get(key), value, "%d %v", i, key)
+		}
+
+		var expected, actual []KV
+		bm.IterateValues(func(key string, value interface{}) bool {
+			expected = append(expected, KV{key, value})
+			return true
+		})
+		err := rm.Iterate(func(key string, value interface{}) bool {
+			actual = append(actual, KV{key, value})
+			return true
+		})
+		assert.NoError(t, err, "%d", i)
+		assert.Equal(t, expected, actual, "%d", i)
+	}
+}
+
+type countingReaderAt struct {
+	r     *bytes.Reader
+	bytes int
+}
+
+func (c *countingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	c.bytes += len(b)
+	return c.r.ReadAt(b, off)
+}
+
+func TestReaderMapPartialReads(t *testing.T) {
+	large := map[string]interface{}{"a": 1, "b": strings.Repeat("x", 100000)}
+	bm := New(large)
+	r := &countingReaderAt{r: bytes.NewReader(bm)}
+	rm := NewReaderAt(r, len(bm))
+	value, err := rm.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, value)
+	assert.True(t, r.bytes < 1000, "reading a small value shouldn't read the whole map, read %d bytes", r.bytes)
+}
+
+func TestReaderMapConcurrent(t *testing.T) {
+	bm := New(m)
+	rm := NewReaderAt(bytes.NewReader(bm), len(bm))
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, key := range bm.Keys() {
+				value, err := rm.Get(key)
+				assert.NoError(t, err, key)
+				assert.Equal(t, bm.Get(key), value, key)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+type failingReaderAt struct{}
+
+func (failingReaderAt) ReadAt(b []byte, off int64) (int, error) {
+	return 0, errors.New("failed")
+}
+
+func TestReaderMapErrors(t *testing.T) {
+	_, err := NewReaderAt(failingReaderAt{}, 100).Get("a")
+	assert.Error(t, err)
+
+	bm := New(m)
+	rm := NewReaderAt(bytes.NewReader(bm[:len(bm)-1]), len(bm))
+	assert.Error(t, rm.Iterate(func(key string, value interface{}) bool { return true }))
+}
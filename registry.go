@@ -0,0 +1,85 @@
+package bytemap
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+const (
+	// TypeUserMin is the lowest type tag available to types registered with
+	// RegisterType. Tags below this are reserved for built-in types.
+	TypeUserMin = 128
+	// TypeUserMax is the highest type tag available to registered types.
+	TypeUserMax = 255
+)
+
+// userType is a codec for a type registered with RegisterType. Values of user
+// types are stored with a 2 byte length prefix, so decode always receives
+// exactly the bytes that encode wrote.
+type userType struct {
+	tag    byte
+	encode func([]byte, interface{}) int
+	decode func([]byte) interface{}
+	length func(interface{}) int
+}
+
+var (
+	userTypesByGoType = make(map[reflect.Type]*userType)
+	userTypesByTag    = make(map[byte]*userType)
+	userTypesMutex    sync.RWMutex
+)
+
+// RegisterType registers a codec for values of the same Go type as sample, so
+// that they can be stored in and read from ByteMaps. tag identifies the type in
+// encoded maps and must be in the range TypeUserMin-TypeUserMax. encode writes
+// the value into the given slice and returns the number of bytes written,
+// decode reconstructs a value from the bytes that encode wrote and length
+// returns how many bytes encode will write for the given value (at most
+// math.MaxUint16).
+//
+// RegisterType panics if tag is out of range or if either the tag or the Go
+// type has already been registered.
+//
+// The registry is safe for concurrent use, but types should be registered
+// before building or reading any maps that contain them (typically in an
+// init function), since until then values of the type are silently encoded as
+// nil and tags of the type decode as nil.
+func RegisterType(sample interface{}, tag byte, encode func([]byte, interface{}) int, decode func([]byte) interface{}, length func(interface{}) int) {
+	if tag < TypeUserMin {
+		panic(fmt.Sprintf("bytemap: type tag %d is reserved for built-in types", tag))
+	}
+	goType := reflect.TypeOf(sample)
+
+	userTypesMutex.Lock()
+	defer userTypesMutex.Unlock()
+	if _, found := userTypesByTag[tag]; found {
+		panic(fmt.Sprintf("bytemap: type tag %d already registered", tag))
+	}
+	if _, found := userTypesByGoType[goType]; found {
+		panic(fmt.Sprintf("bytemap: type %v already registered", goType))
+	}
+	ut := &userType{tag, encode, decode, length}
+	userTypesByTag[tag] = ut
+	userTypesByGoType[goType] = ut
+}
+
+func userTypeFor(value interface{}) *userType {
+	if value == nil {
+		return nil
+	}
+	userTypesMutex.RLock()
+	ut := userTypesByGoType[reflect.TypeOf(value)]
+	userTypesMutex.RUnlock()
+	return ut
+}
+
+func userTypeForTag(t byte) *userType {
+	if t < TypeUserMin {
+		return nil
+	}
+	userTypesMutex.RLock()
+	ut := userTypesByTag[t]
+	userTypesMutex.RUnlock()
+	return ut
+}
@@ -0,0 +1,74 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testUUID [16]byte
+
+const testUUIDTag = TypeUserMin + 1
+
+func init() {
+	RegisterType(testUUID{}, testUUIDTag, func(b []byte, v interface{}) int {
+		uuid := v.(testUUID)
+		return copy(b, uuid[:])
+	}, func(b []byte) interface{} {
+		var uuid testUUID
+		copy(uuid[:], b)
+		return uuid
+	}, func(v interface{}) int {
+		return 16
+	})
+}
+
+// testCounted is a string whose decoder counts how often it's called, so that
+// tests can check which values get decoded.
+type testCounted string
+
+const testCountedTag = TypeUserMin + 2
+
+var testCountedDecodes int
+
+func init() {
+	RegisterType(testCounted(""), testCountedTag, func(b []byte, v interface{}) int {
+		return copy(b, v.(testCounted))
+	}, func(b []byte) interface{} {
+		testCountedDecodes++
+		return testCounted(b)
+	}, func(v interface{}) int {
+		return len(v.(testCounted))
+	})
+}
+
+func TestRegisterType(t *testing.T) {
+	uuid := testUUID{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+	bm := New(map[string]interface{}{
+		"a":    "before",
+		"uuid": uuid,
+		"z":    "after",
+	})
+	assert.Equal(t, uuid, bm.Get("uuid"))
+	assert.Equal(t, "before", bm.Get("a"))
+	assert.Equal(t, "after", bm.Get("z"))
+	assert.Equal(t, 18, len(bm.GetBytes("uuid")))
+	assert.Equal(t, uuid, bm.AsMap()["uuid"])
+
+	sliced := bm.Slice(map[string]bool{"uuid": true, "z": true})
+	assert.Equal(t, uuid, sliced.Get("uuid"))
+	assert.Equal(t, "after", sliced.Get("z"))
+}
+
+func TestRegisterTypeInvalid(t *testing.T) {
+	noop := func(b []byte, v interface{}) int { return 0 }
+	assert.Panics(t, func() {
+		RegisterType(struct{}{}, TypeString, noop, nil, nil)
+	}, "Built-in tag should be rejected")
+	assert.Panics(t, func() {
+		RegisterType(struct{}{}, testUUIDTag, noop, nil, nil)
+	}, "Duplicate tag should be rejected")
+	assert.Panics(t, func() {
+		RegisterType(testUUID{}, TypeUserMax, noop, nil, nil)
+	}, "Duplicate type should be rejected")
+}
@@ -0,0 +1,20 @@
+package bytemap
+
+// Resort returns a ByteMap with the same entries as this one, sorted by key.
+// It repairs maps that were built in violation of the sorted key precondition
+// of FromSortedKeysAndValues or Build, which breaks operations that rely on
+// walking keys in sorted order, such as DecodeSchema, EqualOn and Merge. Keys
+// are stored in stable order, so duplicate keys keep their relative order, and
+// values are stored in the default format.
+//
+// If this map is already sorted, it is returned as is without copying. Ordered
+// maps (see BuildOrdered), arrays and maps built with BuildWithDict are also
+// returned as is.
+func (bm ByteMap) Resort() ByteMap {
+	if bm.IsOrdered() || bm.IsArray() || bm.HasDictKeys() || bm.keysSorted(false) {
+		return bm
+	}
+	entries := bm.rawEntries()
+	sortRawEntries(entries)
+	return mustBuild(buildFromRaw(nil, entries))
+}
@@ -0,0 +1,35 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResort(t *testing.T) {
+	bm := FromSortedKeysAndValues([]string{"c", "a", "d", "b"}, []interface{}{3, 1, nil, "b"})
+	assert.Error(t, bm.Validate())
+	dst := make([]interface{}, 2)
+	assert.NoError(t, bm.DecodeSchema([]string{"a", "c"}, []byte{TypeInt, TypeInt}, dst))
+	assert.Equal(t, []interface{}{nil, 3}, dst, "merge walk should miss keys of mis-ordered map")
+
+	resorted := bm.Resort()
+	assert.NoError(t, resorted.Validate())
+	assert.Equal(t, []string{"a", "b", "c", "d"}, resorted.Keys())
+	assert.Equal(t, bm.AsMap(), resorted.AsMap())
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.Equal(t, bm.Get(key), resorted.Get(key), key)
+	}
+	assert.NoError(t, resorted.DecodeSchema([]string{"a", "c"}, []byte{TypeInt, TypeInt}, dst))
+	assert.Equal(t, []interface{}{1, 3}, dst)
+}
+
+func TestResortSorted(t *testing.T) {
+	bm := New(m)
+	resorted := bm.Resort()
+	assert.Equal(t, bm, resorted)
+	assert.True(t, &bm[0] == &resorted[0], "sorted map should be returned without copying")
+
+	ordered := BuildOrdered([]KV{{"b", 1}, {"a", 2}})
+	assert.Equal(t, ordered, ordered.Resort())
+}
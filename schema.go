@@ -0,0 +1,256 @@
+package bytemap
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DecodeSchema decodes the values of the given keys into dst, where dst[i]
+// receives the value for keys[i]. types[i] specifies the type that keys[i] is
+// expected to have and DecodeSchema returns an error if a key is present with a
+// different type. Keys that are absent or nil are decoded as nil without an
+// error.
+//
+// All keys are resolved in a single walk over the map, so this is cheaper than
+// calling Get for each key when reading a fixed record shape.
+func (bm ByteMap) DecodeSchema(keys []string, types []byte, dst []interface{}) error {
+	if len(types) != len(keys) || len(dst) != len(keys) {
+		return fmt.Errorf("bytemap: schema has %d keys, %d types and %d destinations", len(keys), len(types), len(dst))
+	}
+	for i := range dst {
+		dst[i] = nil
+	}
+
+	var err error
+	bm.resolveKeys(keys, func(i int, t byte, valueOffset int) bool {
+		if t == TypeNil {
+			return true
+		}
+		if t != types[i] {
+			err = fmt.Errorf("bytemap: key %v has type %d, expected %d", keys[i], t, types[i])
+			return false
+		}
+		dst[i] = bm.decodeValueAt(valueOffset, t)
+		return true
+	})
+	return err
+}
+
+// Pluck returns a map of the given keys to their values. Keys that are absent
+// from this ByteMap are omitted from the result, while keys that are present
+// with a nil value are included with a nil value. All keys are resolved in a
+// single walk over the map (unless it IsOrdered).
+func (bm ByteMap) Pluck(keys ...string) map[string]interface{} {
+	result := make(map[string]interface{}, len(keys))
+	bm.resolveKeys(keys, func(i int, t byte, valueOffset int) bool {
+		var value interface{}
+		if t != TypeNil {
+			value = bm.decodeValueAt(valueOffset, t)
+		}
+		result[keys[i]] = value
+		return true
+	})
+	return result
+}
+
+// GetRawMulti gets the types and value bytes of the given keys, where types[i]
+// and values[i] correspond to keys[i]. Keys that are absent (or have a nil
+// value) get TypeNil and nil value bytes. Like GetBytes, the value bytes alias
+// this ByteMap. All keys are resolved in a single walk over the map (unless it
+// IsOrdered).
+func (bm ByteMap) GetRawMulti(keys ...string) (types []byte, values [][]byte) {
+	types = make([]byte, len(keys))
+	values = make([][]byte, len(keys))
+	f := bm.format()
+	bm.resolveKeys(keys, func(i int, t byte, valueOffset int) bool {
+		if t == TypeNil {
+			return true
+		}
+		valueBytes := bm.valueBytesIn(f, valueOffset, t)
+		if valueBytes != nil {
+			types[i], values[i] = t, valueBytes
+		}
+		return true
+	})
+	return types, values
+}
+
+// GetFirst gets the value of the first of the given keys that is present in
+// this ByteMap, e.g. for resolving a setting that may be stored under several
+// names in priority order. key is the key that matched. Keys that are present
+// with a nil value count as present. ok is false if none of the keys are
+// present. All keys are resolved in a single walk over the map (unless it
+// IsOrdered) rather than one lookup per key, and only the matching value is
+// decoded.
+func (bm ByteMap) GetFirst(keys ...string) (key string, value interface{}, ok bool) {
+	best := -1
+	var bestType byte
+	var bestOffset int
+	bm.resolveKeys(keys, func(i int, t byte, valueOffset int) bool {
+		if best < 0 || i < best {
+			best, bestType, bestOffset = i, t, valueOffset
+		}
+		// Nothing can beat the first key
+		return best > 0
+	})
+	if best < 0 {
+		return "", nil, false
+	}
+	if bestType != TypeNil {
+		value = bm.decodeValueAt(bestOffset, bestType)
+	}
+	return keys[best], value, true
+}
+
+// ValueBytesConcat returns the concatenation of the value bytes of the given
+// keys, in the order given rather than sorted order, e.g. for signing a subset
+// of a record's fields. Signers and verifiers must pass the keys in the same
+// order to get the same result. Values are in the default encoding, so the
+// result doesn't depend on the Options a map was built with. Absent keys and
+// nil values contribute no bytes and types aren't included, so callers that
+// need to tell those cases apart should check the schema separately (see
+// Conforms).
+func (bm ByteMap) ValueBytesConcat(keys ...string) []byte {
+	types, values := bm.GetRawMulti(keys...)
+	var result []byte
+	for i, value := range values {
+		result = append(result, bm.defaultEncoding(types[i], value)...)
+	}
+	return result
+}
+
+// MissingKeys returns the keys from required that aren't present in this
+// ByteMap, in sorted order and without duplicates. Keys that are present with a
+// nil value are not missing. All keys are resolved in a single walk over the
+// key region (unless the map IsOrdered) without decoding any values.
+func (bm ByteMap) MissingKeys(required ...string) []string {
+	found := make([]bool, len(required))
+	bm.resolveKeys(required, func(i int, t byte, valueOffset int) bool {
+		found[i] = true
+		return true
+	})
+	var missing []string
+	for _, i := range sortedIndexes(required) {
+		key := required[i]
+		if found[i] || (len(missing) > 0 && missing[len(missing)-1] == key) {
+			continue
+		}
+		missing = append(missing, key)
+	}
+	return missing
+}
+
+// Conforms checks that every key in schema is present in this ByteMap with the
+// type that schema maps it to, which makes it a single-call contract check for
+// inputs. A key that is present with a nil value only conforms to TypeNil. It
+// returns an error describing the first violation in sorted key order, or nil
+// if the map conforms. Keys that aren't in schema are ignored.
+func (bm ByteMap) Conforms(schema map[string]byte) error {
+	keys := make([]string, 0, len(schema))
+	for key := range schema {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	found := make([]bool, len(keys))
+	types := make([]byte, len(keys))
+	bm.resolveKeys(keys, func(i int, t byte, valueOffset int) bool {
+		found[i] = true
+		types[i] = t
+		return true
+	})
+	for i, key := range keys {
+		if !found[i] {
+			return fmt.Errorf("bytemap: required key %v is missing", key)
+		}
+		if types[i] != schema[key] {
+			return fmt.Errorf("bytemap: key %v has type %d, expected type %d", key, types[i], schema[key])
+		}
+	}
+	return nil
+}
+
+// TypeHistogram returns the number of keys in this ByteMap with each value
+// type, including TypeNil. It only walks the key region and doesn't decode any
+// values.
+func (bm ByteMap) TypeHistogram() map[byte]int {
+	result := make(map[byte]int)
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return result
+		}
+		result[e.t]++
+	}
+}
+
+// ColumnType returns the type of the values of key across the given maps, for
+// choosing a typed reader for that column. Maps in which key is absent or nil
+// are skipped. consistent is true if all of the remaining maps store key with
+// the same type t. If they don't, t is the type found first. If no map has a
+// non-nil value for key, t is TypeNil and consistent is false. Only key regions
+// are read, no values are decoded.
+func ColumnType(maps []ByteMap, key string) (t byte, consistent bool) {
+	t = TypeNil
+	for _, bm := range maps {
+		vt, _, found := bm.find(key)
+		if !found || vt == TypeNil {
+			continue
+		}
+		if t == TypeNil {
+			t = vt
+		} else if vt != t {
+			return t, false
+		}
+	}
+	return t, t != TypeNil
+}
+
+// resolveKeys calls cb with the index, type and value offset of each of the
+// given keys that is present in this ByteMap. Unless the map IsOrdered, all
+// keys are resolved in a single walk over the map. If cb returns false,
+// resolving stops.
+func (bm ByteMap) resolveKeys(keys []string, cb func(i int, t byte, valueOffset int) bool) {
+	if bm.IsOrdered() {
+		// Keys aren't sorted, so we can't merge
+		for i, key := range keys {
+			t, valueOffset, found := bm.find(key)
+			if found && !cb(i, t, valueOffset) {
+				return
+			}
+		}
+		return
+	}
+
+	order := sortedIndexes(keys)
+	j := 0
+	c := bm.Cursor()
+	for j < len(order) {
+		e, ok := c.next()
+		if !ok {
+			return
+		}
+		key := bm[e.keyStart:e.keyEnd]
+		for j < len(order) && keys[order[j]] < string(key) {
+			j++
+		}
+		for j < len(order) && keys[order[j]] == string(key) {
+			if !cb(order[j], e.t, e.valueOffset) {
+				return
+			}
+			j++
+		}
+	}
+}
+
+// sortedIndexes returns the indexes of the given keys in sorted key order.
+func sortedIndexes(keys []string) []int {
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return keys[order[a]] < keys[order[b]]
+	})
+	return order
+}
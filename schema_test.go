@@ -0,0 +1,210 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeSchema(t *testing.T) {
+	bm := New(m)
+	keys := []string{"string", "int", "unknown", "nil", "bool", "float64"}
+	types := []byte{TypeString, TypeInt, TypeInt, TypeString, TypeBool, TypeFloat64}
+	dst := make([]interface{}, len(keys))
+	if assert.NoError(t, bm.DecodeSchema(keys, types, dst)) {
+		assert.Equal(t, []interface{}{m["string"], m["int"], nil, nil, m["bool"], m["float64"]}, dst)
+	}
+
+	types[1] = TypeInt64
+	err := bm.DecodeSchema(keys, types, dst)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "int")
+	}
+
+	assert.Error(t, bm.DecodeSchema(keys, types[:1], dst), "Mismatched lengths")
+}
+
+func TestDecodeSchemaOrdered(t *testing.T) {
+	bm := BuildOrdered([]KV{{"z", 1}, {"a", "a"}})
+	dst := make([]interface{}, 2)
+	if assert.NoError(t, bm.DecodeSchema([]string{"a", "z"}, []byte{TypeString, TypeInt}, dst)) {
+		assert.Equal(t, []interface{}{"a", 1}, dst)
+	}
+	assert.Error(t, bm.DecodeSchema([]string{"a", "z"}, []byte{TypeString, TypeString}, dst))
+}
+
+func TestGetFirst(t *testing.T) {
+	bm := New(map[string]interface{}{"a": 1, "b": "b", "c": nil, "z": 26})
+
+	key, value, ok := bm.GetFirst("missing", "z", "b", "a")
+	assert.True(t, ok)
+	assert.Equal(t, "z", key, "earliest present key should win, regardless of sort order")
+	assert.Equal(t, 26, value)
+
+	key, value, ok = bm.GetFirst("b", "a")
+	assert.True(t, ok)
+	assert.Equal(t, "b", key)
+	assert.Equal(t, "b", value)
+
+	key, value, ok = bm.GetFirst("c", "a")
+	assert.True(t, ok, "present nil should count as present")
+	assert.Equal(t, "c", key)
+	assert.Nil(t, value)
+
+	key, _, ok = BuildOrdered([]KV{{"z", 1}, {"a", 2}}).GetFirst("a", "z")
+	assert.True(t, ok)
+	assert.Equal(t, "a", key)
+
+	_, _, ok = bm.GetFirst("x", "y")
+	assert.False(t, ok)
+	_, _, ok = bm.GetFirst()
+	assert.False(t, ok)
+}
+
+func TestValueBytesConcat(t *testing.T) {
+	bm := New(map[string]interface{}{"a": "a", "b": uint16(2), "c": nil})
+	ab := bm.ValueBytesConcat("a", "b")
+	assert.Equal(t, append(bm.GetBytes("a"), bm.GetBytes("b")...), ab)
+	assert.Equal(t, append(bm.GetBytes("b"), bm.GetBytes("a")...), bm.ValueBytesConcat("b", "a"))
+	assert.NotEqual(t, ab, bm.ValueBytesConcat("b", "a"), "order should matter")
+	assert.Equal(t, ab, bm.ValueBytesConcat("a", "c", "missing", "b"), "nil and absent keys should contribute nothing")
+	assert.Empty(t, bm.ValueBytesConcat())
+
+	narrow, err := NewWithOptions(map[string]interface{}{"a": "a", "b": uint16(2)}, Options{StringLenWidth: 1})
+	if assert.NoError(t, err) {
+		assert.Equal(t, ab, narrow.ValueBytesConcat("a", "b"), "result should not depend on format")
+	}
+}
+
+func TestConforms(t *testing.T) {
+	bm := New(map[string]interface{}{"a": 1, "b": "b", "c": nil, "extra": true})
+	assert.NoError(t, bm.Conforms(map[string]byte{"a": TypeInt, "b": TypeString, "c": TypeNil}))
+	assert.NoError(t, bm.Conforms(nil))
+
+	err := bm.Conforms(map[string]byte{"a": TypeInt, "missing": TypeString, "z": TypeBool})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "missing")
+	}
+	err = bm.Conforms(map[string]byte{"a": TypeInt64, "b": TypeString})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "key a has type")
+	}
+	assert.Error(t, bm.Conforms(map[string]byte{"c": TypeString}), "nil should only conform to TypeNil")
+}
+
+func TestColumnType(t *testing.T) {
+	maps := []ByteMap{
+		New(map[string]interface{}{"a": 1, "b": "b", "c": nil}),
+		New(map[string]interface{}{"a": 2, "b": 2.5}),
+		New(map[string]interface{}{"b": "b", "c": nil}),
+		New(map[string]interface{}{"a": nil, "b": "b"}),
+	}
+
+	typ, consistent := ColumnType(maps, "a")
+	assert.True(t, consistent, "absent and nil values should be skipped")
+	assert.EqualValues(t, TypeInt, typ)
+
+	typ, consistent = ColumnType(maps, "b")
+	assert.False(t, consistent)
+	assert.EqualValues(t, TypeString, typ, "mixed column should report the first type found")
+
+	typ, consistent = ColumnType(maps, "c")
+	assert.False(t, consistent, "all-nil column has no type")
+	assert.EqualValues(t, TypeNil, typ)
+
+	typ, consistent = ColumnType(nil, "a")
+	assert.False(t, consistent)
+	assert.EqualValues(t, TypeNil, typ)
+}
+
+func TestTypeHistogram(t *testing.T) {
+	assert.Equal(t, map[byte]int{
+		TypeNil:      1,
+		TypeBool:     1,
+		TypeByte:     1,
+		TypeUInt16:   1,
+		TypeUInt32:   1,
+		TypeUInt64:   1,
+		TypeInt8:     1,
+		TypeInt16:    1,
+		TypeInt32:    1,
+		TypeInt64:    1,
+		TypeInt:      1,
+		TypeFloat32:  1,
+		TypeFloat64:  1,
+		TypeString:   1,
+		TypeTime:     1,
+		TypeUInt:     1,
+		TypeBytes:    1,
+		TypeFloat64s: 1,
+		TypeInts:     1,
+		TypeInt8s:    1,
+	}, New(m).TypeHistogram())
+
+	bm := New(map[string]interface{}{"a": 1, "b": 2, "c": nil, "d": "d", "e": nil, "f": 3})
+	assert.Equal(t, map[byte]int{TypeInt: 3, TypeNil: 2, TypeString: 1}, bm.TypeHistogram())
+	assert.Empty(t, ByteMap(nil).TypeHistogram())
+}
+
+func TestPluck(t *testing.T) {
+	bm := New(m)
+	assert.Equal(t, map[string]interface{}{
+		"string": m["string"],
+		"int":    m["int"],
+		"nil":    nil,
+	}, bm.Pluck("string", "unknown", "nil", "int", "string"))
+	assert.Empty(t, bm.Pluck())
+	assert.Equal(t, map[string]interface{}{"a": "a"}, BuildOrdered([]KV{{"z", 1}, {"a", "a"}}).Pluck("a", "b"))
+}
+
+func TestGetRawMulti(t *testing.T) {
+	bm := New(m)
+	keys := []string{"string", "unknown", "nil", "int", "bytes", "string"}
+	types, values := bm.GetRawMulti(keys...)
+	assert.Equal(t, []byte{TypeString, TypeNil, TypeNil, TypeInt, TypeBytes, TypeString}, types)
+	if assert.Len(t, values, len(keys)) {
+		for i, key := range keys {
+			assert.Equal(t, bm.GetBytes(key), values[i], key)
+		}
+		assert.True(t, &values[0][0] == &bm.GetBytes("string")[0], "value bytes should alias the map")
+	}
+
+	types, values = bm.GetRawMulti()
+	assert.Empty(t, types)
+	assert.Empty(t, values)
+}
+
+func TestMissingKeys(t *testing.T) {
+	bm := New(m)
+	assert.Empty(t, bm.MissingKeys("string", "nil", "int"), "fully present")
+	assert.Equal(t, []string{"a", "z"}, bm.MissingKeys("z", "string", "a", "nil", "z"), "partially present")
+	assert.Equal(t, []string{"x", "y"}, bm.MissingKeys("y", "x"), "disjoint")
+	assert.Empty(t, bm.MissingKeys())
+	assert.Equal(t, []string{"a"}, ByteMap(nil).MissingKeys("a"))
+	assert.Equal(t, []string{"b"}, BuildOrdered([]KV{{"z", 1}, {"a", nil}}).MissingKeys("z", "b", "a"))
+}
+
+var pluckKeys = []string{"key090", "key010", "key050", "key051", "key099", "missing"}
+
+func BenchmarkPluck(b *testing.B) {
+	bm := New(wideMap)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Pluck(pluckKeys...)
+	}
+}
+
+func BenchmarkPluckWithGet(b *testing.B) {
+	bm := New(wideMap)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		result := make(map[string]interface{}, len(pluckKeys))
+		for _, key := range pluckKeys {
+			if value := bm.Get(key); value != nil {
+				result[key] = value
+			}
+		}
+	}
+}
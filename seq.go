@@ -0,0 +1,40 @@
+package bytemap
+
+import (
+	"iter"
+)
+
+// All returns an iterator over the key/value pairs in this ByteMap for use
+// with range, e.g. for key, value := range bm.All() {}. It is equivalent to
+// IterateValues.
+func (bm ByteMap) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		bm.IterateValues(yield)
+	}
+}
+
+// KeySeq returns an iterator over the keys in this ByteMap for use with range.
+// Unlike Keys, it doesn't build a slice or decode any values.
+func (bm ByteMap) KeySeq() iter.Seq[string] {
+	return func(yield func(string) bool) {
+		bm.Iterate(false, false, func(key string, value interface{}, valueBytes []byte) bool {
+			return yield(key)
+		})
+	}
+}
+
+// FromRange constructs a ByteMap from the pairs yielded by rangeFn, which has
+// the shape of an iter.Seq2[string, interface{}], so iterators (including All)
+// can be passed directly and sources like sync.Map can be adapted with a small
+// closure. Sources that can't be ranged over twice are common, so FromRange
+// buffers all pairs (but not their encoded values) before building the map.
+// Pairs may be yielded in any order, and if a key is yielded more than once,
+// the last pair wins (see FromKVs).
+func FromRange(rangeFn func(yield func(key string, value interface{}) bool)) ByteMap {
+	var kvs []KV
+	rangeFn(func(key string, value interface{}) bool {
+		kvs = append(kvs, KV{key, value})
+		return true
+	})
+	return FromKVs(kvs)
+}
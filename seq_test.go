@@ -0,0 +1,68 @@
+package bytemap
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAll(t *testing.T) {
+	bm := New(m)
+	actual := make(map[string]interface{})
+	for key, value := range bm.All() {
+		actual[key] = value
+	}
+	assert.Equal(t, m, actual)
+
+	var keys []string
+	for key := range bm.All() {
+		keys = append(keys, key)
+		if len(keys) == 2 {
+			break
+		}
+	}
+	assert.Equal(t, bm.Keys()[:2], keys)
+
+	for range ByteMap(nil).All() {
+		assert.Fail(t, "empty map should yield nothing")
+	}
+}
+
+func TestKeySeq(t *testing.T) {
+	bm := New(m)
+	var keys []string
+	for key := range bm.KeySeq() {
+		keys = append(keys, key)
+	}
+	assert.Equal(t, bm.Keys(), keys)
+
+	keys = nil
+	for key := range bm.KeySeq() {
+		keys = append(keys, key)
+		if len(keys) == 3 {
+			break
+		}
+	}
+	assert.Equal(t, bm.Keys()[:3], keys)
+}
+
+func TestFromRange(t *testing.T) {
+	var sm sync.Map
+	for key, value := range m {
+		sm.Store(key, value)
+	}
+	bm := FromRange(func(yield func(string, interface{}) bool) {
+		sm.Range(func(key, value interface{}) bool {
+			return yield(key.(string), value)
+		})
+	})
+	assert.Equal(t, New(m), bm)
+
+	assert.Equal(t, New(m), FromRange(New(m).All()))
+	assert.Equal(t, New(nil), FromRange(func(yield func(string, interface{}) bool) {}))
+	dupes := FromRange(func(yield func(string, interface{}) bool) {
+		_ = yield("a", 1) && yield("b", 2) && yield("a", 3)
+	})
+	assert.Equal(t, New(map[string]interface{}{"a": 3, "b": 2}), dupes)
+}
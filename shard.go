@@ -0,0 +1,69 @@
+package bytemap
+
+// Shard partitions the entries of this ByteMap into n ByteMaps by a hash of
+// their keys, so that a wide map can be processed by several workers. Every
+// key (including keys with nil values) ends up in exactly one shard, and since
+// the hash only depends on the key, a given key always lands in the same shard
+// for a given n. Each shard keeps the header of the original and its entries
+// in their original order, and shards that receive no entries are empty maps
+// with just that header. Shard returns nil if n < 1.
+func (bm ByteMap) Shard(n int) []ByteMap {
+	if n < 1 {
+		return nil
+	}
+	type shard struct {
+		keys         [][]byte
+		valueOffsets []int
+		values       [][]byte
+		keysLen      int
+		valuesLen    int
+	}
+	shards := make([]shard, n)
+	header := bm.header()
+	f := bm.format()
+	c := bm.entryCursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			break
+		}
+		s := &shards[int(hashKey(bm[e.keyStart:e.keyEnd])%uint32(n))]
+		key := bm[e.keyStart-SizeKeyLen : e.keyEnd+SizeValueType]
+		if e.t == TypeNil {
+			s.keys = append(s.keys, key)
+			s.valueOffsets = append(s.valueOffsets, -1)
+			s.keysLen += len(key)
+			continue
+		}
+		value := bm.valueBytesIn(f, e.valueOffset, e.t)
+		if value == nil {
+			// Value is out of bounds, stop here
+			break
+		}
+		s.keys = append(s.keys, key)
+		s.valueOffsets = append(s.valueOffsets, s.valuesLen)
+		s.values = append(s.values, value)
+		s.keysLen += len(key) + SizeValueOffset
+		s.valuesLen += len(value)
+	}
+
+	result := make([]ByteMap, n)
+	for i, s := range shards {
+		result[i] = buildFromSliced(header, s.keysLen, s.valuesLen, s.keys, s.valueOffsets, s.values)
+	}
+	return result
+}
+
+// hashKey computes the 32-bit FNV-1a hash of the given key without allocating.
+func hashKey(key []byte) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for _, b := range key {
+		h ^= uint32(b)
+		h *= prime32
+	}
+	return h
+}
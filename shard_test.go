@@ -0,0 +1,49 @@
+package bytemap
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShard(t *testing.T) {
+	bm := New(m)
+	assert.Nil(t, bm.Shard(0))
+
+	shards := bm.Shard(1)
+	if assert.Len(t, shards, 1) {
+		assert.Equal(t, bm, shards[0], "a single shard should be identical to the original")
+	}
+
+	for _, n := range []int{2, 3, len(m) * 2} {
+		shards := bm.Shard(n)
+		if !assert.Len(t, shards, n) {
+			continue
+		}
+		var keys []string
+		empty := 0
+		for _, shard := range shards {
+			assert.NoError(t, shard.Validate())
+			if shard.Len() == 0 {
+				empty++
+			}
+			for _, key := range shard.Keys() {
+				keys = append(keys, key)
+				assert.Equal(t, bm.Get(key), shard.Get(key))
+			}
+		}
+		sort.Strings(keys)
+		assert.Equal(t, bm.Keys(), keys, "shards should cover every key exactly once (n=%d)", n)
+		if n > len(m) {
+			assert.True(t, empty > 0, "some shards should be empty")
+		}
+	}
+
+	ordered := BuildOrdered([]KV{{"b", 1}, {"a", nil}, {"c", "c"}})
+	for _, shard := range ordered.Shard(2) {
+		assert.True(t, shard.IsOrdered())
+		assert.NoError(t, shard.Validate())
+	}
+	assert.Equal(t, ordered, ordered.Shard(1)[0])
+}
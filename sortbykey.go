@@ -0,0 +1,96 @@
+package bytemap
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Kinds of values in the order in which SortByKey sorts them
+const (
+	sortKindMissing = iota
+	sortKindBool
+	sortKindNumber
+	sortKindString
+	sortKindTime
+	sortKindOther
+)
+
+type sortValue struct {
+	kind int
+	num  float64
+	str  string
+	tm   time.Time
+}
+
+// SortByKey sorts the given maps in place by the value of the given key. The
+// sort is stable. Values are ordered as follows:
+//
+//   - maps missing the key (or holding nil for it) sort first
+//   - then bools, with false before true
+//   - then numbers of any type, compared by numeric value with NaN first (like
+//     sort.Float64s). Integers with a magnitude above 2^53 are compared as
+//     float64 and may compare as equal to nearby values.
+//   - then strings, compared lexicographically
+//   - then times, compared chronologically
+//   - then all other values, which are left in their original order
+//
+// Each map's value is decoded only once.
+func SortByKey(maps []ByteMap, key string) {
+	values := make([]sortValue, len(maps))
+	for i, bm := range maps {
+		values[i] = toSortValue(bm.Get(key))
+	}
+	sort.Stable(&mapsByKey{maps, values})
+}
+
+func toSortValue(value interface{}) sortValue {
+	if value == nil {
+		return sortValue{kind: sortKindMissing}
+	}
+	if num, ok := toFloat64(value); ok {
+		return sortValue{kind: sortKindNumber, num: num}
+	}
+	switch v := value.(type) {
+	case bool:
+		if v {
+			return sortValue{kind: sortKindBool, num: 1}
+		}
+		return sortValue{kind: sortKindBool}
+	case string:
+		return sortValue{kind: sortKindString, str: v}
+	case time.Time:
+		return sortValue{kind: sortKindTime, tm: v}
+	}
+	return sortValue{kind: sortKindOther}
+}
+
+type mapsByKey struct {
+	maps   []ByteMap
+	values []sortValue
+}
+
+func (s *mapsByKey) Len() int {
+	return len(s.maps)
+}
+
+func (s *mapsByKey) Swap(i, j int) {
+	s.maps[i], s.maps[j] = s.maps[j], s.maps[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
+
+func (s *mapsByKey) Less(i, j int) bool {
+	a, b := s.values[i], s.values[j]
+	if a.kind != b.kind {
+		return a.kind < b.kind
+	}
+	switch a.kind {
+	case sortKindBool, sortKindNumber:
+		return a.num < b.num || (math.IsNaN(a.num) && !math.IsNaN(b.num))
+	case sortKindString:
+		return a.str < b.str
+	case sortKindTime:
+		return a.tm.Before(b.tm)
+	}
+	return false
+}
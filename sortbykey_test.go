@@ -0,0 +1,43 @@
+package bytemap
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortByKey(t *testing.T) {
+	maps := []ByteMap{
+		New(map[string]interface{}{"id": 1, "score": 5.5}),
+		New(map[string]interface{}{"id": 2}),
+		New(map[string]interface{}{"id": 3, "score": -2.25}),
+		New(map[string]interface{}{"id": 4, "score": math.NaN()}),
+		New(map[string]interface{}{"id": 5, "score": 3}),
+		New(map[string]interface{}{"id": 6, "score": nil}),
+		New(map[string]interface{}{"id": 7, "score": float32(4)}),
+		New(map[string]interface{}{"id": 8, "score": uint16(5)}),
+		New(map[string]interface{}{"id": 9, "score": "text"}),
+	}
+	SortByKey(maps, "score")
+	var ids []int
+	for _, bm := range maps {
+		ids = append(ids, bm.Get("id").(int))
+	}
+	assert.Equal(t, []int{2, 6, 4, 3, 5, 7, 8, 1, 9}, ids)
+}
+
+func TestSortByKeyMixedKinds(t *testing.T) {
+	maps := []ByteMap{
+		New(map[string]interface{}{"v": "b"}),
+		New(map[string]interface{}{"v": true}),
+		New(map[string]interface{}{"v": "a"}),
+		New(map[string]interface{}{"v": false}),
+	}
+	SortByKey(maps, "v")
+	var values []interface{}
+	for _, bm := range maps {
+		values = append(values, bm.Get("v"))
+	}
+	assert.Equal(t, []interface{}{false, true, "a", "b"}, values)
+}
@@ -0,0 +1,450 @@
+package bytemap
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CanonicalText is a deterministic, line based text representation of a
+// ByteMap that is meant for golden files and diffs. Every key is on its own
+// line, in sorted key order, in the form:
+//
+//	"key"=type:value
+//
+// where the key is quoted like a Go string literal, type is the name of the
+// value's type (see below) and the format of value depends on the type:
+//
+//	nil                                  nil (without a value)
+//	bool                                 true or false
+//	byte, uint16, uint32, uint64, uint,
+//	int8, int16, int32, int64, int       decimal integer
+//	float32, float64                     shortest decimal representation that
+//	                                     round trips (e.g. 1.5, 1e+21, NaN, +Inf)
+//	string                               quoted Go string literal
+//	time                                 RFC 3339 with nanoseconds, in UTC
+//	bytes                                hexadecimal
+//	proto                                hexadecimal marshaled message
+//	json                                 quoted compact JSON, as stored
+//	ints, int8s, float64s                [v1,v2,...] with elements formatted
+//	                                     like the corresponding scalar type
+//	strings                              ["s1","s2",...]
+//	bigint                               decimal integer
+//	bigfloat                             precision:value, e.g. 53:12.5 (the
+//	                                     rounding mode isn't preserved)
+//	bytemap                              quoted CanonicalText of the nested map
+//	array                                quoted CanonicalText of the array
+//	user<tag>                            hexadecimal encoding of a registered
+//	                                     user type, e.g. user129:0102
+//
+// Arrays (see NewArray) are represented without keys, with one type:value line
+// per element in positional order. Every line, including the last, ends with a
+// newline. Compressed values are represented by their uncompressed value.
+
+var (
+	typeNames = map[byte]string{
+		TypeBool:     "bool",
+		TypeByte:     "byte",
+		TypeUInt16:   "uint16",
+		TypeUInt32:   "uint32",
+		TypeUInt64:   "uint64",
+		TypeUInt:     "uint",
+		TypeInt8:     "int8",
+		TypeInt16:    "int16",
+		TypeInt32:    "int32",
+		TypeInt64:    "int64",
+		TypeInt:      "int",
+		TypeFloat32:  "float32",
+		TypeFloat64:  "float64",
+		TypeString:   "string",
+		TypeTime:     "time",
+		TypeBytes:    "bytes",
+		TypeFloat64s: "float64s",
+		TypeInts:     "ints",
+		TypeInt8s:    "int8s",
+		TypeStrings:  "strings",
+		TypeBigInt:   "bigint",
+		TypeBigFloat: "bigfloat",
+		TypeByteMap:  "bytemap",
+	}
+	typesByName = func() map[string]byte {
+		result := make(map[string]byte, len(typeNames))
+		for t, name := range typeNames {
+			result[name] = t
+		}
+		return result
+	}()
+)
+
+// CanonicalText returns the canonical text representation of this ByteMap (see
+// above).
+func (bm ByteMap) CanonicalText() string {
+	var lines []string
+	if bm.IsArray() {
+		for i, n := 0, bm.ArrayLen(); i < n; i++ {
+			elementOffset := bm.headerLen() + i*sizeElement
+			t := bm[elementOffset]
+			valueOffset, _ := bm.uint32At(elementOffset + SizeValueType)
+			lines = append(lines, bm.canonicalValue(t, valueOffset)+"\n")
+		}
+		return strings.Join(lines, "")
+	}
+
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			break
+		}
+		key := strconv.Quote(string(bm[e.keyStart:e.keyEnd]))
+		lines = append(lines, key+"="+bm.canonicalValue(e.t, e.valueOffset)+"\n")
+	}
+	if bm.IsOrdered() {
+		sort.Strings(lines)
+	}
+	return strings.Join(lines, "")
+}
+
+func (bm ByteMap) canonicalValue(t byte, valueOffset int) string {
+	if t == TypeNil {
+		return "nil"
+	}
+	if t >= TypeUserMin {
+		valueBytes := bm.valueBytesAt(valueOffset, t)
+		if valueBytes == nil {
+			return "nil"
+		}
+		return fmt.Sprintf("user%d:%x", t, valueBytes[2:])
+	}
+	if t == TypeJSON {
+		valueBytes := bm.valueBytesAt(valueOffset, t)
+		if valueBytes == nil {
+			return "nil"
+		}
+		return "json:" + strconv.Quote(string(valueBytes[4:]))
+	}
+	value := bm.decodeValueAt(valueOffset, t)
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case []byte:
+		if t == TypeProto {
+			return "proto:" + hex.EncodeToString(v)
+		}
+		return "bytes:" + hex.EncodeToString(v)
+	case string:
+		return "string:" + strconv.Quote(v)
+	case ByteMap:
+		name := "bytemap"
+		if v.IsArray() {
+			name = "array"
+		}
+		return name + ":" + strconv.Quote(v.CanonicalText())
+	}
+	return typeNames[t] + ":" + formatCanonical(value)
+}
+
+func formatCanonical(value interface{}) string {
+	switch v := value.(type) {
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case []int:
+		elements := make([]string, len(v))
+		for i, e := range v {
+			elements[i] = strconv.Itoa(e)
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	case []int8:
+		elements := make([]string, len(v))
+		for i, e := range v {
+			elements[i] = strconv.Itoa(int(e))
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	case []float64:
+		elements := make([]string, len(v))
+		for i, e := range v {
+			elements[i] = strconv.FormatFloat(e, 'g', -1, 64)
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	case []string:
+		elements := make([]string, len(v))
+		for i, e := range v {
+			elements[i] = strconv.Quote(e)
+		}
+		return "[" + strings.Join(elements, ",") + "]"
+	case *big.Int:
+		return v.String()
+	case *big.Float:
+		return strconv.Itoa(int(v.Prec())) + ":" + v.Text('g', -1)
+	}
+	return fmt.Sprint(value)
+}
+
+// ParseCanonicalText parses the canonical text representation of a ByteMap
+// (see CanonicalText). Keys don't need to be in sorted order, but each key may
+// only appear once.
+func ParseCanonicalText(text string) (ByteMap, error) {
+	var entries []rawEntry
+	keyLines := make(map[string]int)
+	var values []interface{}
+	isArray := false
+	for i, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		if line == "" && text == "" {
+			break
+		}
+		if i == 0 {
+			isArray = !strings.HasPrefix(line, `"`)
+		}
+		var key string
+		if !isArray {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil || !strings.HasPrefix(line[len(quoted):], "=") {
+				return nil, fmt.Errorf("bytemap: line %d: expected quoted key followed by =", i+1)
+			}
+			key, _ = strconv.Unquote(quoted)
+			if first, found := keyLines[key]; found {
+				return nil, fmt.Errorf("bytemap: line %d: duplicate key %.16q, first seen on line %d", i+1, key, first)
+			}
+			keyLines[key] = i + 1
+			line = line[len(quoted)+1:]
+		}
+		value, t, raw, err := parseCanonicalValue(line)
+		if err != nil {
+			return nil, fmt.Errorf("bytemap: line %d: %v", i+1, err)
+		}
+		if isArray {
+			if raw != nil {
+				return nil, fmt.Errorf("bytemap: line %d: user types aren't supported in arrays", i+1)
+			}
+			values = append(values, value)
+			continue
+		}
+		if s, ok := value.(string); ok && len(s) > math.MaxUint16 {
+			// Too long for the default encoding, see isWideString
+			t, raw = TypeString, enc.AppendUint32(nil, uint32(len(s)))
+			raw = append(raw, s...)
+		}
+		if raw == nil {
+			raw = make([]byte, encodedLength(value))
+			t, _ = encodeValue(raw, value)
+		}
+		entries = append(entries, rawEntry{key, t, raw})
+	}
+	if isArray {
+		return NewArray(values), nil
+	}
+	sortRawEntries(entries)
+	return buildFromRaw(nil, entries)
+}
+
+// parseCanonicalValue parses a type:value pair. User types are returned as raw
+// encoded bytes, everything else as a value.
+func parseCanonicalValue(s string) (value interface{}, t byte, raw []byte, err error) {
+	if s == "nil" {
+		return nil, TypeNil, nil, nil
+	}
+	colon := strings.IndexByte(s, ':')
+	if colon < 0 {
+		return nil, 0, nil, fmt.Errorf("expected type:value")
+	}
+	name, s := s[:colon], s[colon+1:]
+	if strings.HasPrefix(name, "user") {
+		tag, err := strconv.ParseUint(name[4:], 10, 8)
+		if err != nil || tag < TypeUserMin {
+			return nil, 0, nil, fmt.Errorf("invalid user type %v", name)
+		}
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) > 1<<16-1 {
+			return nil, 0, nil, fmt.Errorf("invalid user value %v", s)
+		}
+		raw = make([]byte, 2+len(b))
+		enc.PutUint16(raw, uint16(len(b)))
+		copy(raw[2:], b)
+		return nil, byte(tag), raw, nil
+	}
+	if name == "proto" {
+		b, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("invalid proto value %v", s)
+		}
+		raw = make([]byte, 4+len(b))
+		enc.PutUint32(raw, uint32(len(b)))
+		copy(raw[4:], b)
+		return nil, TypeProto, raw, nil
+	}
+	if name == "json" {
+		text, err := strconv.Unquote(s)
+		if err != nil || !json.Valid([]byte(text)) {
+			return nil, 0, nil, fmt.Errorf("invalid json value %v", s)
+		}
+		raw = make([]byte, 4+len(text))
+		enc.PutUint32(raw, uint32(len(text)))
+		copy(raw[4:], text)
+		return nil, TypeJSON, raw, nil
+	}
+	if name == "array" || name == "bytemap" {
+		text, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, 0, nil, fmt.Errorf("invalid nested %v: %v", name, err)
+		}
+		if text == "" && name == "array" {
+			return NewArray(nil), TypeByteMap, nil, nil
+		}
+		nested, err := ParseCanonicalText(text)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if nested.IsArray() != (name == "array") {
+			return nil, 0, nil, fmt.Errorf("nested %v doesn't match its contents", name)
+		}
+		return nested, TypeByteMap, nil, nil
+	}
+	t, found := typesByName[name]
+	if !found {
+		return nil, 0, nil, fmt.Errorf("unknown type %v", name)
+	}
+	value, err = parseCanonical(t, s)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("invalid %v %v: %v", name, s, err)
+	}
+	return value, t, nil, nil
+}
+
+func parseCanonical(t byte, s string) (interface{}, error) {
+	switch t {
+	case TypeBool:
+		return strconv.ParseBool(s)
+	case TypeByte:
+		v, err := strconv.ParseUint(s, 10, 8)
+		return byte(v), err
+	case TypeUInt16:
+		v, err := strconv.ParseUint(s, 10, 16)
+		return uint16(v), err
+	case TypeUInt32:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return uint32(v), err
+	case TypeUInt64:
+		return strconv.ParseUint(s, 10, 64)
+	case TypeUInt:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return uint(v), err
+	case TypeInt8:
+		v, err := strconv.ParseInt(s, 10, 8)
+		return int8(v), err
+	case TypeInt16:
+		v, err := strconv.ParseInt(s, 10, 16)
+		return int16(v), err
+	case TypeInt32:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return int32(v), err
+	case TypeInt64:
+		return strconv.ParseInt(s, 10, 64)
+	case TypeInt:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return int(v), err
+	case TypeFloat32:
+		v, err := strconv.ParseFloat(s, 32)
+		return float32(v), err
+	case TypeFloat64:
+		return strconv.ParseFloat(s, 64)
+	case TypeString:
+		return strconv.Unquote(s)
+	case TypeTime:
+		tm, err := time.Parse(time.RFC3339Nano, s)
+		return tm.UTC(), err
+	case TypeBytes:
+		return hex.DecodeString(s)
+	case TypeBigInt:
+		v, ok := new(big.Int).SetString(s, 10)
+		if !ok {
+			return nil, fmt.Errorf("not an integer")
+		}
+		return v, nil
+	case TypeBigFloat:
+		parts := strings.SplitN(s, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected precision:value")
+		}
+		prec, err := strconv.ParseUint(parts[0], 10, 32)
+		if err != nil {
+			return nil, err
+		}
+		v, ok := new(big.Float).SetPrec(uint(prec)).SetString(parts[1])
+		if !ok {
+			return nil, fmt.Errorf("not a number")
+		}
+		return v, nil
+	}
+
+	// Lists
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("expected [...]")
+	}
+	s = s[1 : len(s)-1]
+	var elements []string
+	if t == TypeStrings {
+		elements = []string{}
+		for s != "" {
+			quoted, err := strconv.QuotedPrefix(s)
+			if err != nil {
+				return nil, err
+			}
+			element, _ := strconv.Unquote(quoted)
+			elements = append(elements, element)
+			s = s[len(quoted):]
+			if s != "" {
+				if s[0] != ',' || len(s) == 1 {
+					return nil, fmt.Errorf("expected , between strings")
+				}
+				s = s[1:]
+			}
+		}
+		return elements, nil
+	}
+	if s != "" {
+		elements = strings.Split(s, ",")
+	}
+	switch t {
+	case TypeInts:
+		result := make([]int, len(elements))
+		for i, e := range elements {
+			v, err := strconv.ParseInt(e, 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = int(v)
+		}
+		return result, nil
+	case TypeInt8s:
+		result := make([]int8, len(elements))
+		for i, e := range elements {
+			v, err := strconv.ParseInt(e, 10, 8)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = int8(v)
+		}
+		return result, nil
+	case TypeFloat64s:
+		result := make([]float64, len(elements))
+		for i, e := range elements {
+			v, err := strconv.ParseFloat(e, 64)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = v
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unsupported type")
+}
@@ -0,0 +1,96 @@
+package bytemap
+
+import (
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonicalText(t *testing.T) {
+	bm := New(map[string]interface{}{
+		"b":        true,
+		"a=b":      "line\nbreak \"quoted\"",
+		"int":      -5,
+		"uint16":   uint16(7),
+		"float32":  float32(0.1),
+		"nan":      math.NaN(),
+		"time":     time.Date(2014, 02, 05, 17, 6, 3, 9, time.UTC),
+		"bytes":    []byte{0xde, 0xad},
+		"ints":     []int{1, -2},
+		"strings":  []string{"x,y", ""},
+		"nil":      nil,
+		"nested":   New(map[string]interface{}{"c": 1}),
+		"array":    NewArray([]interface{}{1, "two", nil}),
+		"bigfloat": big.NewFloat(2.5),
+	})
+	expected := `"a=b"=string:"line\nbreak \"quoted\""
+"array"=array:"int:1\nstring:\"two\"\nnil\n"
+"b"=bool:true
+"bigfloat"=bigfloat:53:2.5
+"bytes"=bytes:dead
+"float32"=float32:0.1
+"int"=int:-5
+"ints"=ints:[1,-2]
+"nan"=float64:NaN
+"nested"=bytemap:"\"c\"=int:1\n"
+"nil"=nil
+"strings"=strings:["x,y",""]
+"time"=time:2014-02-05T17:06:03.000000009Z
+"uint16"=uint16:7
+`
+	assert.Equal(t, expected, bm.CanonicalText())
+
+	parsed, err := ParseCanonicalText(expected)
+	if assert.NoError(t, err) {
+		assert.Equal(t, bm, parsed)
+	}
+}
+
+func TestCanonicalTextRoundTrip(t *testing.T) {
+	input := make(map[string]interface{}, len(m))
+	for key, value := range m {
+		input[key] = value
+	}
+	input["bigint"] = new(big.Int).Lsh(big.NewInt(1), 100)
+	input["strings"] = []string{}
+	input["emptyArray"] = NewArray(nil)
+	input["emptyMap"] = New(nil)
+	input["user"] = testUUID{1, 2, 3}
+	for _, bm := range []ByteMap{
+		New(input),
+		New(nil),
+		NewArray([]interface{}{1, nil, "a"}),
+		BuildOrdered([]KV{{"z", 1}, {"a", "a"}}),
+	} {
+		text := bm.CanonicalText()
+		parsed, err := ParseCanonicalText(text)
+		if assert.NoError(t, err, text) {
+			assert.Equal(t, text, parsed.CanonicalText())
+			assert.True(t, bm.Equal(parsed), text)
+		}
+	}
+}
+
+func TestParseCanonicalTextErrors(t *testing.T) {
+	for _, text := range []string{
+		`"a"`,
+		`"a"=`,
+		`"a"=int`,
+		`"a"=int:x`,
+		`"a"=byte:256`,
+		`"a"=unknown:1`,
+		`"a"=string:unquoted`,
+		`"a"=ints:1,2`,
+		`"a"=strings:["a""b"]`,
+		`"a"=user5:00`,
+		`"a"=bytemap:"int:1\n"`,
+		`a=int:1`,
+		"\"a\"=int:1\n\"b\"=int:2\n\"a\"=int:3",
+	} {
+		_, err := ParseCanonicalText(text)
+		assert.Error(t, err, text)
+	}
+}
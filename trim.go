@@ -0,0 +1,67 @@
+package bytemap
+
+// IsEmpty indicates whether this Value is nil, a zero number or an empty
+// string, byte slice, slice or ByteMap. JSON values are empty if they're null
+// or an empty string, array or object. Bools and times are never empty.
+func (v Value) IsEmpty() bool {
+	switch v.t {
+	case TypeNil:
+		return true
+	case TypeString:
+		f := v.bm.format()
+		l, ok := v.bm.stringLenAt(f, v.offset)
+		return ok && l == 0
+	case TypeBytes, TypeInt8s, TypeStrings, TypeInts, TypeFloat64s:
+		l, ok := v.bm.uint16At(v.offset)
+		return ok && l == 0
+	case TypeByteMap, TypeProto:
+		l, ok := v.bm.uint32At(v.offset)
+		return ok && l == 0
+	case TypeJSON:
+		b := v.Bytes()
+		return len(b) >= 4 && isEmptyJSON(b[4:])
+	}
+	f, ok := v.Float()
+	return ok && f == 0
+}
+
+// TrimEmpty returns a new ByteMap without the keys whose values are empty (see
+// Value.IsEmpty). Values are copied without being decoded.
+func (bm ByteMap) TrimEmpty() ByteMap {
+	return bm.TrimFunc(func(key string, v Value) bool {
+		return v.IsEmpty()
+	})
+}
+
+// TrimFunc returns a new ByteMap without the keys for which isEmpty returns
+// true. Values are copied without being decoded. If this ByteMap IsOrdered, so
+// is the result.
+func (bm ByteMap) TrimFunc(isEmpty func(key string, v Value) bool) ByteMap {
+	var entries []rawEntry
+	f := bm.format()
+	c := bm.entryCursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			break
+		}
+		key := string(bm[e.keyStart:e.keyEnd])
+		if isEmpty(key, Value{bm, e.t, e.valueOffset}) {
+			continue
+		}
+		entry := rawEntry{key: key, t: e.t}
+		if e.t != TypeNil {
+			entry.value = bm.defaultEncoding(e.t, bm.valueBytesIn(f, e.valueOffset, e.t))
+			if entry.value == nil {
+				// Truncated value, stop here
+				break
+			}
+		}
+		entries = append(entries, entry)
+	}
+	var header []byte
+	if flags := bm.flags() & (FlagOrdered | FlagDictKeys); flags != 0 {
+		header = newHeader(flags)
+	}
+	return mustBuild(buildFromRaw(header, entries))
+}
@@ -0,0 +1,55 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrimEmpty(t *testing.T) {
+	bm := New(map[string]interface{}{
+		"nil":          nil,
+		"emptystring":  "",
+		"emptybytes":   []byte{},
+		"emptystrings": []string{},
+		"emptyints":    []int{},
+		"zeroint":      0,
+		"zerobyte":     byte(0),
+		"zerofloat":    0.0,
+		"emptymap":     ByteMap{},
+		"string":       "a",
+		"bytes":        []byte{0},
+		"int":          -1,
+		"float":        0.5,
+		"false":        false,
+		"strings":      []string{""},
+		"time":         m["time"],
+	})
+	trimmed := bm.TrimEmpty()
+	assert.NoError(t, trimmed.Validate())
+	assert.True(t, len(trimmed) < len(bm))
+	assert.Equal(t, map[string]interface{}{
+		"string":  "a",
+		"bytes":   []byte{0},
+		"int":     -1,
+		"float":   0.5,
+		"false":   false,
+		"strings": []string{""},
+		"time":    m["time"],
+	}, trimmed.AsMap())
+
+	full := New(m)
+	assert.Equal(t, len(m)-1, full.TrimEmpty().Len(), "only nil should be trimmed")
+	assert.Empty(t, ByteMap(nil).TrimEmpty())
+}
+
+func TestTrimFunc(t *testing.T) {
+	bm := BuildOrdered([]KV{{"b", 0}, {"a", ""}, {"c", nil}, {"d", 1}})
+	trimmed := bm.TrimFunc(func(key string, v Value) bool {
+		return v.IsNil() || key == "d"
+	})
+	assert.True(t, trimmed.IsOrdered())
+	assert.Equal(t, []string{"b", "a"}, trimmed.Keys())
+	assert.Equal(t, 0, trimmed.Get("b"))
+	assert.Equal(t, "", trimmed.Get("a"))
+}
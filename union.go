@@ -0,0 +1,92 @@
+package bytemap
+
+import (
+	"sort"
+)
+
+// UnionKeys returns the sorted union of the keys of all of the given maps. It
+// merges the maps' already sorted key regions without decoding any values.
+// Keys present in multiple maps are only included once.
+func UnionKeys(maps ...ByteMap) []string {
+	cursors := make([]*Cursor, 0, len(maps))
+	heads := make([][]byte, 0, len(maps))
+	var unsorted []string
+	for _, bm := range maps {
+		if bm.IsOrdered() {
+			unsorted = append(unsorted, bm.Keys()...)
+			continue
+		}
+		c := bm.Cursor()
+		if e, ok := c.next(); ok {
+			cursors = append(cursors, c)
+			heads = append(heads, bm[e.keyStart:e.keyEnd])
+		}
+	}
+
+	var result []string
+	for len(cursors) > 0 {
+		// Find the smallest head. The number of maps is usually small, so a
+		// linear scan is cheaper than maintaining a heap.
+		min := 0
+		for i := 1; i < len(heads); i++ {
+			if string(heads[i]) < string(heads[min]) {
+				min = i
+			}
+		}
+		key := string(heads[min])
+		if len(result) == 0 || result[len(result)-1] != key {
+			result = append(result, key)
+		}
+
+		// Advance all cursors positioned at this key
+		for i := 0; i < len(cursors); {
+			if string(heads[i]) != key {
+				i++
+				continue
+			}
+			c := cursors[i]
+			if e, ok := c.next(); ok {
+				heads[i] = c.bm[e.keyStart:e.keyEnd]
+				i++
+			} else {
+				cursors = append(cursors[:i], cursors[i+1:]...)
+				heads = append(heads[:i], heads[i+1:]...)
+			}
+		}
+	}
+
+	if len(unsorted) > 0 {
+		// Merge in keys from ordered maps
+		result = append(result, unsorted...)
+		sort.Strings(result)
+		deduped := result[:0]
+		for i, key := range result {
+			if i == 0 || key != result[i-1] {
+				deduped = append(deduped, key)
+			}
+		}
+		result = deduped
+	}
+	return result
+}
+
+// ToColumns transposes the given maps into columns, e.g. for handing them to a
+// columnar consumer. keys is the sorted union of the keys of all maps (see
+// UnionKeys) and columns[j][i] is the value of keys[j] in maps[i], which is nil
+// if that map doesn't have the key (or has a nil value for it).
+func ToColumns(maps []ByteMap) (keys []string, columns [][]interface{}) {
+	keys = UnionKeys(maps...)
+	indexes := make(map[string]int, len(keys))
+	columns = make([][]interface{}, len(keys))
+	for j, key := range keys {
+		indexes[key] = j
+		columns[j] = make([]interface{}, len(maps))
+	}
+	for i, bm := range maps {
+		bm.IterateValues(func(key string, value interface{}) bool {
+			columns[indexes[key]][i] = value
+			return true
+		})
+	}
+	return keys, columns
+}
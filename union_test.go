@@ -0,0 +1,76 @@
+package bytemap
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnionKeys(t *testing.T) {
+	a := New(map[string]interface{}{"a": 1, "c": nil, "e": "e"})
+	b := New(map[string]interface{}{"b": 1, "c": 2, "f": "f"})
+	c := New(map[string]interface{}{"a": 1, "f": 2, "z": true})
+	assert.Equal(t, []string{"a", "b", "c", "e", "f", "z"}, UnionKeys(a, b, c))
+	assert.Equal(t, []string{"a", "c", "e"}, UnionKeys(a, a))
+	assert.Equal(t, []string{"a", "c", "e"}, UnionKeys(a, nil))
+	assert.Empty(t, UnionKeys())
+	assert.Empty(t, UnionKeys(nil, nil))
+
+	ordered := BuildOrdered([]KV{{"y", 1}, {"b", 2}})
+	assert.Equal(t, []string{"a", "b", "c", "e", "y"}, UnionKeys(a, ordered))
+}
+
+var unionMaps []ByteMap
+
+func init() {
+	for i := 0; i < 10; i++ {
+		m := make(map[string]interface{})
+		for j := 0; j < 20; j++ {
+			m[fmt.Sprintf("key%03d", i*5+j)] = j
+		}
+		unionMaps = append(unionMaps, New(m))
+	}
+}
+
+func BenchmarkUnionKeys(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		UnionKeys(unionMaps...)
+	}
+}
+
+func BenchmarkUnionKeysMap(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		seen := make(map[string]bool)
+		for _, bm := range unionMaps {
+			bm.IterateValueBytes(func(key string, valueBytes []byte) bool {
+				seen[key] = true
+				return true
+			})
+		}
+		result := make([]string, 0, len(seen))
+		for key := range seen {
+			result = append(result, key)
+		}
+		sort.Strings(result)
+	}
+}
+
+func TestToColumns(t *testing.T) {
+	keys, columns := ToColumns([]ByteMap{
+		New(map[string]interface{}{"a": 1, "b": "b1", "c": 1.5}),
+		New(map[string]interface{}{"a": 2, "c": 2.5}),
+		BuildOrdered([]KV{{"c", 3.5}, {"b", "b3"}, {"a", 3}}),
+	})
+	assert.Equal(t, []string{"a", "b", "c"}, keys)
+	assert.Equal(t, [][]interface{}{
+		{1, 2, 3},
+		{"b1", nil, "b3"},
+		{1.5, 2.5, 3.5},
+	}, columns)
+
+	keys, columns = ToColumns(nil)
+	assert.Empty(t, keys)
+	assert.Empty(t, columns)
+}
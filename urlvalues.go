@@ -0,0 +1,56 @@
+package bytemap
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+)
+
+// NewURLValues creates a new ByteMap from the given url.Values. Keys with
+// exactly one value are stored as a string, so that the common case of a
+// single valued query parameter can be read directly as a string. Keys with
+// zero or several values are stored as a []string.
+//
+// Since url.Values usually come from untrusted requests, NewURLValues returns
+// an error instead of panicking (like New) if a key is longer than MaxKeyLen
+// bytes, and instead of truncating if a single value is longer than
+// math.MaxUint16 bytes or the values of a key take up more than that in total.
+func NewURLValues(v url.Values) (ByteMap, error) {
+	for key, values := range v {
+		if err := checkURLValues(key, values); err != nil {
+			return nil, err
+		}
+	}
+	return BuildStrict(func(cb func(string, interface{})) {
+		for key, values := range v {
+			cb(key, urlValue(values))
+		}
+	}, func(key string) interface{} {
+		return urlValue(v[key])
+	}, false)
+}
+
+// checkURLValues checks that the values of the given key fit into the string
+// or []string that urlValue stores them as.
+func checkURLValues(key string, values []string) error {
+	size := 0
+	if len(values) == 1 {
+		size = len(values[0])
+	} else {
+		for _, value := range values {
+			// Each string has a 2 byte length
+			size += 2 + len(value)
+		}
+	}
+	if size > math.MaxUint16 {
+		return fmt.Errorf("bytemap: values for key %.16q take up %d bytes, which exceeds the maximum of %d bytes", key, size, math.MaxUint16)
+	}
+	return nil
+}
+
+func urlValue(values []string) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
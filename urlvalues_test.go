@@ -0,0 +1,46 @@
+package bytemap
+
+import (
+	"math"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewURLValues(t *testing.T) {
+	v, err := url.ParseQuery("a=1&b=2&b=3&b=&c=")
+	if !assert.NoError(t, err) {
+		return
+	}
+	v["d"] = []string{}
+	bm, err := NewURLValues(v)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, bm.Validate())
+	assert.Equal(t, map[string]interface{}{
+		"a": "1",
+		"b": []string{"2", "3", ""},
+		"c": "",
+		"d": []string{},
+	}, bm.AsMap())
+}
+
+func TestNewURLValuesTooLong(t *testing.T) {
+	_, err := NewURLValues(url.Values{strings.Repeat("k", MaxKeyLen+1): {"v"}})
+	assert.Error(t, err, "key too long")
+
+	longest := strings.Repeat("v", math.MaxUint16)
+	bm, err := NewURLValues(url.Values{"a": {longest}, "b": {longest[:math.MaxUint16/2-2], longest[:math.MaxUint16/2-2]}})
+	if assert.NoError(t, err) {
+		assert.NoError(t, bm.Validate())
+		assert.Equal(t, longest, bm.Get("a"))
+		assert.Len(t, bm.Get("b"), 2)
+	}
+	_, err = NewURLValues(url.Values{"a": {longest + "v"}})
+	assert.Error(t, err, "value too long")
+	_, err = NewURLValues(url.Values{"a": {longest[:math.MaxUint16/2], longest[:math.MaxUint16/2]}})
+	assert.Error(t, err, "values too long")
+}
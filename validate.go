@@ -0,0 +1,147 @@
+package bytemap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Validate checks that this ByteMap is well formed, i.e. that every key and
+// value lies within its bounds (including the declared lengths of strings and
+// other variable length values), that every value has a known type and that
+// the keys of unordered maps are sorted. It's useful for checking ByteMaps
+// received from untrusted sources before reading them. For maps built with
+// BuildWithDict, it checks the key IDs in place of keys.
+//
+// Accessors never panic on malformed ByteMaps, but they may return partial
+// results, so Validate is the only way to tell that something is wrong.
+func (bm ByteMap) Validate() error {
+	if _, ok := FormatVersion(bm); !ok {
+		return fmt.Errorf("bytemap: unknown format")
+	}
+	if bm.headerLen() > len(bm) {
+		return fmt.Errorf("bytemap: truncated header")
+	}
+	if bm.IsArray() {
+		return bm.validateArray()
+	}
+
+	f := bm.format()
+	ordered := bm.IsOrdered()
+	var prevKey []byte
+	c := bm.entryCursor()
+	for i := 0; ; i++ {
+		e, ok := c.next()
+		if !ok {
+			break
+		}
+		key := bm[e.keyStart:e.keyEnd]
+		if !ordered && i > 0 && bytes.Compare(prevKey, key) >= 0 {
+			return fmt.Errorf("bytemap: key %q is out of order", key)
+		}
+		prevKey = key
+		if e.t == TypeNil {
+			continue
+		}
+		if e.valueOffset < c.firstValueOffset {
+			return fmt.Errorf("bytemap: value of %q starts before the value region", key)
+		}
+		if err := validateValue(f, e.t, bm.valueBytesIn(f, e.valueOffset, e.t)); err != nil {
+			return fmt.Errorf("bytemap: value of %q: %v", key, err)
+		}
+	}
+	if c.corrupt {
+		return fmt.Errorf("bytemap: truncated key at offset %d", c.offset)
+	}
+	if c.firstValueOffset > 0 && c.offset != c.firstValueOffset {
+		return fmt.Errorf("bytemap: keys overlap the value region")
+	}
+	return nil
+}
+
+// IsSorted indicates whether the keys of this ByteMap are in strictly
+// increasing lexicographic order, which lookups on unordered maps depend on. It
+// only walks the key region, so it's much cheaper than Validate, but it doesn't
+// check values and only catches a truncated key region by returning false.
+// Ordered maps may or may not be sorted, and maps without keys (including
+// arrays) are trivially sorted.
+func (bm ByteMap) IsSorted() bool {
+	return bm.keysSorted(true)
+}
+
+// keysSorted indicates whether the keys of this ByteMap are in increasing
+// lexicographic order, which must be strict (i.e. without duplicate keys) if
+// strict is true. It returns false if the key region is truncated.
+func (bm ByteMap) keysSorted(strict bool) bool {
+	var prevKey []byte
+	c := Cursor{bm: bm, offset: bm.keysStart(), bigEndian: bm.bigEndian()}
+	for i := 0; ; i++ {
+		e, ok := c.next()
+		if !ok {
+			return !c.corrupt
+		}
+		key := bm[e.keyStart:e.keyEnd]
+		if i > 0 {
+			cmp := bytes.Compare(prevKey, key)
+			if cmp > 0 || (strict && cmp == 0) {
+				return false
+			}
+		}
+		prevKey = key
+	}
+}
+
+func (bm ByteMap) validateArray() error {
+	start := bm.headerLen()
+	end, ok := bm.uint32At(start + SizeValueType)
+	if !ok {
+		if len(bm) == start {
+			// Empty array
+			return nil
+		}
+		return fmt.Errorf("bytemap: truncated array element")
+	}
+	if end < start || end > len(bm) || (end-start)%sizeElement != 0 {
+		return fmt.Errorf("bytemap: invalid array element region")
+	}
+	f := bm.format()
+	for offset := start; offset < end; offset += sizeElement {
+		t := bm[offset]
+		if t == TypeNil {
+			continue
+		}
+		valueOffset := int(f.uint32(bm[offset+SizeValueType:]))
+		if valueOffset < end {
+			return fmt.Errorf("bytemap: element %d starts before the value region", (offset-start)/sizeElement)
+		}
+		if err := validateValue(f, t, bm.valueBytesIn(f, valueOffset, t)); err != nil {
+			return fmt.Errorf("bytemap: element %d: %v", (offset-start)/sizeElement, err)
+		}
+	}
+	return nil
+}
+
+// validateValue checks the internal structure of the given value bytes of type
+// t, encoded in the given format, which are nil if the value is out of bounds.
+func validateValue(f format, t byte, valueBytes []byte) error {
+	if valueBytes == nil {
+		return fmt.Errorf("type %d is invalid or out of bounds", t)
+	}
+	switch t {
+	case TypeStrings:
+		if decodeStrings(f, valueBytes[2:]) == nil {
+			return fmt.Errorf("strings are out of bounds")
+		}
+	case TypeByteMap:
+		return ByteMap(valueBytes[4:]).Validate()
+	case TypeCompressed:
+		if decodeCompressed(f, valueBytes[4:]) == nil {
+			return fmt.Errorf("invalid compressed value")
+		}
+	case TypeJSON:
+		if !json.Valid(valueBytes[4:]) {
+			return fmt.Errorf("invalid JSON value")
+		}
+	}
+	return nil
+}
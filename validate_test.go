@@ -0,0 +1,114 @@
+package bytemap
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	valid := []ByteMap{
+		nil,
+		New(m),
+		New(map[string]interface{}{}),
+		New(map[string]interface{}{"a": nil, "b": nil}),
+		New(map[string]interface{}{"a": nil, "b": "", "c": 1}),
+		BuildOrdered([]KV{{"z", 1}, {"a", "a"}}),
+		NewArray([]interface{}{1, nil, "a"}),
+		NewArray(nil),
+	}
+	for i, bm := range valid {
+		assert.NoError(t, bm.Validate(), "map %d should be valid", i)
+	}
+}
+
+func TestValidateStringPastEnd(t *testing.T) {
+	bm := New(map[string]interface{}{"a": 1, "b": "hello"})
+	_, valueOffset, found := bm.find("b")
+	if !assert.True(t, found) {
+		return
+	}
+	// Declare a string length that runs past the end of the map
+	enc.PutUint16(bm[valueOffset:], 1000)
+
+	assert.Error(t, bm.Validate())
+	assert.Nil(t, bm.Get("b"))
+	assert.Nil(t, bm.GetBytes("b"))
+	assert.Equal(t, 1, bm.Get("a"))
+	assert.NotPanics(t, func() {
+		bm.AsMap()
+		bm.Keys()
+		bm.Iterate(true, true, func(key string, value interface{}, valueBytes []byte) bool {
+			return true
+		})
+		bm.Slice(map[string]bool{"a": true, "b": true})
+		bm.Split(map[string]bool{"a": true})
+		bm.SliceExcept("a")
+	})
+}
+
+func TestValidateUnsortedKeys(t *testing.T) {
+	bm := New(map[string]interface{}{"a": 1, "b": 2})
+	// Rename "a" to "c" so that it sorts after "b"
+	bm[SizeKeyLen] = 'c'
+	assert.Error(t, bm.Validate())
+}
+
+func TestIsSorted(t *testing.T) {
+	assert.True(t, New(m).IsSorted())
+	assert.True(t, New(nil).IsSorted())
+	assert.True(t, FromSortedKeysAndValues([]string{"a", "b"}, []interface{}{1, 2}).IsSorted())
+	assert.False(t, FromSortedKeysAndValues([]string{"b", "a"}, []interface{}{1, 2}).IsSorted(), "mis-ordered keys")
+	assert.False(t, FromSortedKeysAndValues([]string{"a", "a"}, []interface{}{1, 2}).IsSorted(), "duplicate keys")
+	assert.False(t, BuildOrdered([]KV{{"b", 1}, {"a", 2}}).IsSorted())
+	assert.True(t, BuildOrdered([]KV{{"a", 1}, {"b", 2}}).IsSorted())
+	assert.False(t, New(m)[:3].IsSorted(), "truncated key region")
+
+	duplicates := FromSortedKeysAndValues([]string{"a", "a", "b"}, []interface{}{1, 2, 3})
+	assert.True(t, duplicates.keysSorted(false), "duplicate keys are sorted if not strict")
+	assert.Equal(t, duplicates, duplicates.Resort(), "Resort should keep duplicates in place")
+
+	bm := New(m)
+	assert.Zero(t, testing.AllocsPerRun(100, func() { bm.IsSorted() }))
+}
+
+func TestValidateTruncated(t *testing.T) {
+	// Every truncation of these leaves part of an entry or value behind
+	bms := []ByteMap{
+		New(m),
+		New(map[string]interface{}{"a": nil, "b": nil})[:7],
+		NewArray([]interface{}{1, "a"})[:24],
+	}
+	for _, bm := range bms {
+		for i := 1; i < len(bm)-SizeHeader; i++ {
+			truncated := bm[:len(bm)-i]
+			assert.Error(t, truncated.Validate(), "map truncated by %d bytes should be invalid", i)
+			assert.NotPanics(t, func() {
+				truncated.AsMap()
+				truncated.AsSlice()
+				truncated.Slice(map[string]bool{"string": true})
+			})
+		}
+	}
+}
+
+func TestValidateCorrupt(t *testing.T) {
+	orig := New(m)
+	keys := orig.Keys()
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 1000; i++ {
+		bm := make(ByteMap, len(orig))
+		copy(bm, orig)
+		bm[r.Intn(len(bm))] = byte(r.Intn(256))
+		assert.NotPanics(t, func() {
+			bm.Validate()
+			bm.AsMap()
+			for _, key := range keys {
+				bm.Get(key)
+			}
+			bm.Slice(map[string]bool{"string": true, "uint64": true})
+			bm.SliceExcept("string")
+		})
+	}
+}
@@ -0,0 +1,285 @@
+package bytemap
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Value is a value in a ByteMap that is only decoded on demand. It aliases the
+// ByteMap's underlying bytes, so using it doesn't allocate unless the value is
+// converted to an interface{}, string or slice.
+type Value struct {
+	bm     ByteMap
+	t      byte
+	offset int
+}
+
+// Type returns the type of this Value.
+func (v Value) Type() byte {
+	return v.t
+}
+
+// IsNil indicates whether this Value is nil.
+func (v Value) IsNil() bool {
+	return v.t == TypeNil
+}
+
+// Bytes returns the encoded bytes of this Value, aliasing the underlying
+// ByteMap, or nil if the Value is nil.
+func (v Value) Bytes() []byte {
+	if v.t == TypeNil {
+		return nil
+	}
+	return v.bm.valueBytesAt(v.offset, v.t)
+}
+
+// Interface decodes this Value into the same type that Get would return.
+func (v Value) Interface() interface{} {
+	if v.t == TypeNil {
+		return nil
+	}
+	return v.bm.decodeValueAt(v.offset, v.t)
+}
+
+// Bool returns the value of a TypeBool Value. ok is false for other types.
+func (v Value) Bool() (result bool, ok bool) {
+	if v.t != TypeBool {
+		return false, false
+	}
+	b := v.Bytes()
+	if b == nil {
+		return false, false
+	}
+	return b[0] == 1, true
+}
+
+// Int returns the value of any integer typed Value as an int64. ok is false for
+// non-integer types and for unsigned values that don't fit into an int64.
+func (v Value) Int() (result int64, ok bool) {
+	b := v.Bytes()
+	if b == nil {
+		return 0, false
+	}
+	return intValue(v.bm.format(), v.t, b)
+}
+
+// intValue decodes the value bytes b of type t, encoded in format f, as an
+// int64 (see Value.Int).
+func intValue(f format, t byte, b []byte) (result int64, ok bool) {
+	switch t {
+	case TypeByte:
+		return int64(b[0]), true
+	case TypeUInt16:
+		return int64(f.uint16(b)), true
+	case TypeUInt32:
+		return int64(f.uint32(b)), true
+	case TypeUInt64, TypeUInt:
+		u := f.uint64(b)
+		if u > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(u), true
+	case TypeInt8:
+		return int64(int8(b[0])), true
+	case TypeInt16:
+		return int64(int16(f.uint16(b))), true
+	case TypeInt32:
+		return int64(int32(f.uint32(b))), true
+	case TypeInt64, TypeInt:
+		return int64(f.uint64(b)), true
+	}
+	return 0, false
+}
+
+// Float returns the value of any numeric (integer or float) Value as a float64.
+// ok is false for non-numeric types. Integers with a magnitude above 2^53 lose
+// precision in the conversion.
+func (v Value) Float() (result float64, ok bool) {
+	b := v.Bytes()
+	if b == nil {
+		return 0, false
+	}
+	return floatValue(v.bm.format(), v.t, b)
+}
+
+// floatValue decodes the value bytes b of type t, encoded in format f, as a
+// float64 (see Value.Float).
+func floatValue(f format, t byte, b []byte) (result float64, ok bool) {
+	switch t {
+	case TypeFloat32:
+		return float64(math.Float32frombits(f.uint32(b))), true
+	case TypeFloat64:
+		return math.Float64frombits(f.uint64(b)), true
+	case TypeUInt64, TypeUInt:
+		return float64(f.uint64(b)), true
+	}
+	i, ok := intValue(f, t, b)
+	return float64(i), ok
+}
+
+// Str returns the value of a TypeString Value, or of a TypeCompressed Value
+// holding a string. ok is false for other types.
+func (v Value) Str() (result string, ok bool) {
+	if v.t != TypeString && v.t != TypeCompressed {
+		return "", false
+	}
+	s, ok := v.Interface().(string)
+	return s, ok
+}
+
+// Time returns the value of a TypeTime Value. ok is false for other types.
+func (v Value) Time() (result time.Time, ok bool) {
+	if v.t != TypeTime {
+		return time.Time{}, false
+	}
+	tm, ok := v.Interface().(time.Time)
+	return tm, ok
+}
+
+// GetInt gets the value of any integer type (including byte) for the given key
+// as an int64. ok is false if the key is not found, if its value is nil or not
+// an integer (floats aren't converted) or if it's an unsigned value that
+// doesn't fit into an int64.
+func (bm ByteMap) GetInt(key string) (result int64, ok bool) {
+	t, valueOffset, found := bm.find(key)
+	if !found || t == TypeNil {
+		return 0, false
+	}
+	return Value{bm, t, valueOffset}.Int()
+}
+
+// GetTriBool gets a boolean that may be unset. set is false if the key is not
+// found or its value is nil (or not a bool), otherwise value holds the stored
+// bool. This maps the distinction between false and unset onto TypeBool and
+// TypeNil.
+func (bm ByteMap) GetTriBool(key string) (value bool, set bool) {
+	t, valueOffset, found := bm.find(key)
+	if !found || t != TypeBool {
+		return false, false
+	}
+	return Value{bm, t, valueOffset}.Bool()
+}
+
+// IterateTyped iterates over the key/value pairs in this ByteMap and calls the
+// given callback with each. Unlike IterateValues, values aren't decoded up
+// front, so the only allocation per entry is its key. If the callback returns
+// false, iteration stops even if there remain unread values.
+func (bm ByteMap) IterateTyped(cb func(key string, v Value) bool) {
+	bm.iterateTyped(func(key []byte, v Value) bool {
+		return cb(string(key), v)
+	})
+}
+
+// iterateTyped is like IterateTyped, but passes keys as slices of this
+// ByteMap so that iterating doesn't allocate at all.
+func (bm ByteMap) iterateTyped(cb func(key []byte, v Value) bool) {
+	c := Cursor{bm: bm, offset: bm.keysStart(), bigEndian: bm.bigEndian()}
+	for {
+		e, ok := c.next()
+		if !ok {
+			return
+		}
+		if !cb(bm[e.keyStart:e.keyEnd], Value{bm, e.t, e.valueOffset}) {
+			return
+		}
+	}
+}
+
+// IterateLazy iterates over the key/value pairs in this ByteMap and calls the
+// given callback with each key, the type of its value and a function that
+// decodes the value on demand, so that values of entries the callback isn't
+// interested in are never decoded. decode returns what Get would return and
+// decodes the value again every time it's called. If the callback returns
+// false, iteration stops even if there remain unread values.
+func (bm ByteMap) IterateLazy(cb func(key string, t byte, decode func() interface{}) bool) {
+	f := bm.format()
+	c := bm.Cursor()
+	for {
+		e, ok := c.next()
+		if !ok {
+			return
+		}
+		decode := func() interface{} {
+			if e.t == TypeNil {
+				return nil
+			}
+			return bm.decodeValueIn(f, e.valueOffset, e.t)
+		}
+		if !cb(string(bm[e.keyStart:e.keyEnd]), e.t, decode) {
+			return
+		}
+	}
+}
+
+// IterateSortedByValue calls the given callback with each key/value pair in
+// this ByteMap, in the order defined by less. The sort is stable, so pairs with
+// equal values are visited in key order (sorted order unless the map
+// IsOrdered). If the callback returns false, iteration stops even if there
+// remain unvisited values. All pairs are collected before sorting, so this
+// allocates in proportion to the number of keys.
+func (bm ByteMap) IterateSortedByValue(less func(a, b Value) bool, cb func(key string, v Value) bool) {
+	var keys []string
+	var values []Value
+	bm.iterateTyped(func(key []byte, v Value) bool {
+		keys = append(keys, string(key))
+		values = append(values, v)
+		return true
+	})
+	order := make([]int, len(keys))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return less(values[order[i]], values[order[j]])
+	})
+	for _, i := range order {
+		if !cb(keys[i], values[i]) {
+			return
+		}
+	}
+}
+
+// Float64Values returns the values of all numeric (integer and float) keys as
+// float64s, in key order (sorted order unless the map IsOrdered). Keys with
+// non-numeric or nil values are skipped, so the result doesn't say which key
+// each value came from. Integers with a magnitude above 2^53 lose precision in
+// the conversion and float32 values are widened, so they may not print the
+// way they were written (e.g. float32(0.1) becomes 0.10000000149011612).
+func (bm ByteMap) Float64Values() []float64 {
+	var result []float64
+	bm.iterateTyped(func(key []byte, v Value) bool {
+		if f, ok := v.Float(); ok {
+			result = append(result, f)
+		}
+		return true
+	})
+	return result
+}
+
+// MaxNumeric returns the key with the largest numeric (integer or float) value
+// in this ByteMap and that value as a float64. Non-numeric, nil and NaN values
+// are skipped. If several keys share the largest value, the one that comes
+// first in sorted order wins. ok is false if there are no numeric values.
+func (bm ByteMap) MaxNumeric() (key string, value float64, ok bool) {
+	return bm.numericExtremum(func(a, b float64) bool { return a > b })
+}
+
+// MinNumeric is like MaxNumeric, but returns the key with the smallest value.
+func (bm ByteMap) MinNumeric() (key string, value float64, ok bool) {
+	return bm.numericExtremum(func(a, b float64) bool { return a < b })
+}
+
+func (bm ByteMap) numericExtremum(better func(a, b float64) bool) (key string, value float64, ok bool) {
+	bm.iterateTyped(func(k []byte, v Value) bool {
+		f, numeric := v.Float()
+		if !numeric || math.IsNaN(f) {
+			return true
+		}
+		if !ok || better(f, value) || (f == value && string(k) < key) {
+			key, value, ok = string(k), f, true
+		}
+		return true
+	})
+	return key, value, ok
+}
@@ -0,0 +1,290 @@
+package bytemap
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIterateTyped(t *testing.T) {
+	bm := New(m)
+	seen := 0
+	bm.IterateTyped(func(key string, v Value) bool {
+		seen++
+		assert.Equal(t, m[key], v.Interface(), key)
+		assert.Equal(t, bm.GetBytes(key), v.Bytes(), key)
+		assert.Equal(t, m[key] == nil, v.IsNil(), key)
+		return true
+	})
+	assert.Equal(t, len(m), seen)
+
+	seen = 0
+	bm.IterateTyped(func(key string, v Value) bool {
+		seen++
+		return false
+	})
+	assert.Equal(t, 1, seen)
+}
+
+func TestIterateLazy(t *testing.T) {
+	testCountedDecodes = 0
+	bm := New(map[string]interface{}{"a": testCounted("a"), "b": "b", "c": testCounted("c"), "nil": nil})
+	result := make(map[string]interface{})
+	var keys []string
+	bm.IterateLazy(func(key string, typ byte, decode func() interface{}) bool {
+		keys = append(keys, key)
+		if key != "a" {
+			result[key] = decode()
+		}
+		return true
+	})
+	assert.Equal(t, bm.Keys(), keys)
+	assert.Equal(t, map[string]interface{}{"b": "b", "c": testCounted("c"), "nil": nil}, result)
+	assert.Equal(t, 1, testCountedDecodes, "only values whose decode was called should be decoded")
+
+	testCountedDecodes = 0
+	count := 0
+	bm.IterateLazy(func(key string, typ byte, decode func() interface{}) bool {
+		count++
+		return false
+	})
+	assert.Equal(t, 1, count)
+	assert.Zero(t, testCountedDecodes)
+}
+
+func TestValueAccessors(t *testing.T) {
+	bm := New(m)
+	values := make(map[string]Value)
+	bm.IterateTyped(func(key string, v Value) bool {
+		values[key] = v
+		return true
+	})
+
+	b, ok := values["bool"].Bool()
+	assert.True(t, ok)
+	assert.True(t, b)
+	_, ok = values["int"].Bool()
+	assert.False(t, ok)
+
+	for key, expected := range map[string]int64{
+		"byte":   math.MaxUint8,
+		"uint16": math.MaxUint16,
+		"uint32": math.MaxUint32,
+		"int8":   math.MaxInt8,
+		"int16":  math.MaxInt16,
+		"int32":  math.MaxInt32,
+		"int64":  math.MaxInt64,
+		"int":    math.MaxInt64,
+	} {
+		i, ok := values[key].Int()
+		assert.True(t, ok, key)
+		assert.Equal(t, expected, i, key)
+	}
+	_, ok = values["uint64"].Int()
+	assert.False(t, ok, "uint64 overflowing int64 should not be an int")
+	_, ok = values["float64"].Int()
+	assert.False(t, ok)
+
+	f, ok := values["float32"].Float()
+	assert.True(t, ok)
+	assert.Equal(t, float64(math.MaxFloat32), f)
+	f, ok = values["uint64"].Float()
+	assert.True(t, ok)
+	assert.Equal(t, float64(math.MaxUint64), f)
+	f, ok = values["int16"].Float()
+	assert.True(t, ok)
+	assert.Equal(t, float64(math.MaxInt16), f)
+	_, ok = values["string"].Float()
+	assert.False(t, ok)
+
+	s, ok := values["string"].Str()
+	assert.True(t, ok)
+	assert.Equal(t, m["string"], s)
+	_, ok = values["bytes"].Str()
+	assert.False(t, ok)
+
+	tm, ok := values["time"].Time()
+	assert.True(t, ok)
+	assert.Equal(t, m["time"], tm)
+
+	assert.True(t, values["nil"].IsNil())
+	assert.Nil(t, values["nil"].Interface())
+	assert.Nil(t, values["nil"].Bytes())
+	_, ok = values["nil"].Int()
+	assert.False(t, ok)
+}
+
+func TestGetInt(t *testing.T) {
+	bm := New(m)
+	expected := map[string]int64{
+		"byte":   math.MaxUint8,
+		"uint16": math.MaxUint16,
+		"uint32": math.MaxUint32,
+		"int8":   math.MaxInt8,
+		"int16":  math.MaxInt16,
+		"int32":  math.MaxInt32,
+		"int64":  math.MaxInt64,
+		"int":    math.MaxInt64,
+	}
+	for key, value := range expected {
+		i, ok := bm.GetInt(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, value, i, key)
+	}
+	for _, key := range []string{"uint64", "uint", "float32", "float64", "string", "bool", "time", "nil", "missing"} {
+		_, ok := bm.GetInt(key)
+		assert.False(t, ok, key)
+	}
+
+	small := New(map[string]interface{}{"uint64": uint64(5), "uint": uint(6), "negative": int8(-7)})
+	for key, value := range map[string]int64{"uint64": 5, "uint": 6, "negative": -7} {
+		i, ok := small.GetInt(key)
+		assert.True(t, ok, key)
+		assert.Equal(t, value, i, key)
+	}
+}
+
+func TestGetTriBool(t *testing.T) {
+	bm := New(map[string]interface{}{"true": true, "false": false, "nil": nil, "int": 1})
+	for _, test := range []struct {
+		key   string
+		value bool
+		set   bool
+	}{
+		{"true", true, true},
+		{"false", false, true},
+		{"nil", false, false},
+		{"missing", false, false},
+		{"int", false, false},
+	} {
+		value, set := bm.GetTriBool(test.key)
+		assert.Equal(t, test.value, value, test.key)
+		assert.Equal(t, test.set, set, test.key)
+	}
+}
+
+func TestFloat64Values(t *testing.T) {
+	bm := New(map[string]interface{}{
+		"a": 1,
+		"b": "not a number",
+		"c": uint64(3),
+		"d": nil,
+		"e": 4.5,
+		"f": true,
+		"g": int8(-6),
+		"h": float32(0.5),
+	})
+	assert.Equal(t, []float64{1, 3, 4.5, -6, 0.5}, bm.Float64Values())
+	assert.Nil(t, New(map[string]interface{}{"a": "a"}).Float64Values())
+}
+
+func TestIterateSortedByValue(t *testing.T) {
+	bm := New(map[string]interface{}{
+		"a": 3,
+		"b": 1.5,
+		"c": uint16(3),
+		"d": -2,
+		"e": 3.0,
+		"f": 1.5,
+	})
+	ascending := func(a, b Value) bool {
+		fa, _ := a.Float()
+		fb, _ := b.Float()
+		return fa < fb
+	}
+	var keys []string
+	var values []float64
+	bm.IterateSortedByValue(ascending, func(key string, v Value) bool {
+		f, _ := v.Float()
+		keys = append(keys, key)
+		values = append(values, f)
+		return true
+	})
+	assert.Equal(t, []string{"d", "b", "f", "a", "c", "e"}, keys, "equal values should stay in key order")
+	assert.Equal(t, []float64{-2, 1.5, 1.5, 3, 3, 3}, values)
+
+	keys = nil
+	bm.IterateSortedByValue(ascending, func(key string, v Value) bool {
+		keys = append(keys, key)
+		return len(keys) < 2
+	})
+	assert.Equal(t, []string{"d", "b"}, keys)
+}
+
+// numericMap has multi-character keys because converting single byte keys to
+// strings doesn't allocate.
+var numericMap = map[string]interface{}{
+	"int":     1000,
+	"int64":   int64(-1000),
+	"float64": 1.5,
+	"uint32":  uint32(70000),
+	"float32": float32(2.5),
+	"large":   123456789,
+}
+
+func TestIterateTypedAllocs(t *testing.T) {
+	bm := New(numericMap)
+	allocs := testing.AllocsPerRun(100, func() {
+		bm.IterateTyped(func(key string, v Value) bool {
+			v.Float()
+			return true
+		})
+	})
+	assert.LessOrEqual(t, allocs, float64(len(numericMap)), "only keys should allocate")
+}
+
+func BenchmarkIterateTyped(b *testing.B) {
+	bm := New(numericMap)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.IterateTyped(func(key string, v Value) bool {
+			v.Float()
+			return true
+		})
+	}
+}
+
+func BenchmarkIterateValues(b *testing.B) {
+	bm := New(numericMap)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.IterateValues(func(key string, value interface{}) bool {
+			return true
+		})
+	}
+}
+
+func TestMaxMinNumeric(t *testing.T) {
+	bm := New(map[string]interface{}{
+		"a": "not a number",
+		"b": 3,
+		"c": float32(-1.5),
+		"d": nil,
+		"e": uint8(7),
+		"f": 7.0,
+		"g": math.NaN(),
+		"h": []float64{100},
+		"i": -1.5,
+	})
+	key, value, ok := bm.MaxNumeric()
+	assert.True(t, ok)
+	assert.Equal(t, "e", key, "ties should go to the first key in sorted order")
+	assert.Equal(t, 7.0, value)
+
+	key, value, ok = bm.MinNumeric()
+	assert.True(t, ok)
+	assert.Equal(t, "c", key)
+	assert.Equal(t, -1.5, value)
+
+	key, _, ok = BuildOrdered([]KV{{"z", 1}, {"y", 1}}).MaxNumeric()
+	assert.True(t, ok)
+	assert.Equal(t, "y", key, "ties should go to the first key in sorted order, even in ordered maps")
+
+	_, _, ok = New(map[string]interface{}{"a": "a", "b": nil}).MaxNumeric()
+	assert.False(t, ok)
+	_, _, ok = New(nil).MinNumeric()
+	assert.False(t, ok)
+}
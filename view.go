@@ -0,0 +1,46 @@
+package bytemap
+
+// MapView is a read-only view of a subset of the keys in a ByteMap. Unlike
+// Slice, creating a view doesn't copy anything, but every read has to check
+// the requested key against the allowed keys and then scan the original map.
+//
+// Views are cheaper than Slice when only a handful of reads will be made
+// against the subset, for example when reading a couple of keys once. When
+// the subset will be read many times, stored or iterated over, Slice is
+// usually the better choice since subsequent reads scan a smaller map.
+type MapView struct {
+	bm   ByteMap
+	keys []string
+}
+
+// View returns a MapView that only exposes the given keys of this ByteMap.
+func (bm ByteMap) View(keys ...string) MapView {
+	return MapView{bm, keys}
+}
+
+// Get gets the value for the given key, or nil if the key is not found or is
+// not one of the keys allowed by this view.
+func (v MapView) Get(key string) interface{} {
+	if !v.allows(key) {
+		return nil
+	}
+	return v.bm.Get(key)
+}
+
+// GetBytes gets the bytes slice for the given key, or nil if the key is not
+// found or is not one of the keys allowed by this view.
+func (v MapView) GetBytes(key string) []byte {
+	if !v.allows(key) {
+		return nil
+	}
+	return v.bm.GetBytes(key)
+}
+
+func (v MapView) allows(key string) bool {
+	for _, allowed := range v.keys {
+		if key == allowed {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package bytemap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestView(t *testing.T) {
+	bm := New(m)
+	v := bm.View("string", "int", "unknown")
+	assert.Equal(t, m["string"], v.Get("string"))
+	assert.Equal(t, m["int"], v.Get("int"))
+	assert.Equal(t, bm.GetBytes("int"), v.GetBytes("int"))
+	assert.Nil(t, v.Get("unknown"))
+	assert.Nil(t, v.Get("bool"), "Disallowed key should not be visible")
+	assert.Nil(t, v.GetBytes("bool"), "Disallowed key should not be visible")
+	assert.Nil(t, bm.View().Get("string"))
+}
+
+func BenchmarkViewGet(b *testing.B) {
+	bm := New(m)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.View(testKeys...).Get("int16")
+	}
+}
+
+func BenchmarkSliceGet(b *testing.B) {
+	bm := New(m)
+	includeKeys := make(map[string]bool, len(testKeys))
+	for _, key := range testKeys {
+		includeKeys[key] = true
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		bm.Slice(includeKeys).Get("int16")
+	}
+}